@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// badRequestError marks an approve failure caused by the request itself,
+// which the handler reports as 400 rather than a Tailscale error.
+type badRequestError struct {
+	err error
+}
+
+func (e *badRequestError) Error() string { return e.err.Error() }
+func (e *badRequestError) Unwrap() error { return e.err }
+
+// errNoTags rejects an approve with no tags, which would call SetTags with an
+// empty list and clear nothing.
+var errNoTags = errors.New("at least one tag is required")
+
+// deviceLocks serializes approvals per device, so two reviewers approving the
+// same device at once do not race between validation and SetTags. An entry is
+// dropped once nobody holds or waits for it, so unknown device IDs sent by
+// clients do not accumulate.
+type deviceLocks struct {
+	mu    sync.Mutex
+	locks map[string]*deviceLock
+}
+
+type deviceLock struct {
+	mu   sync.Mutex
+	refs int // holders plus waiters, guarded by deviceLocks.mu
+}
+
+// lock blocks until deviceID is free and returns the function releasing it.
+func (l *deviceLocks) lock(deviceID string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*deviceLock)
+	}
+	dl, ok := l.locks[deviceID]
+	if !ok {
+		dl = &deviceLock{}
+		l.locks[deviceID] = dl
+	}
+	dl.refs++
+	l.mu.Unlock()
+
+	dl.mu.Lock()
+	return func() {
+		dl.mu.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		dl.refs--
+		if dl.refs == 0 {
+			delete(l.locks, deviceID)
+		}
+	}
+}
+
+// approveOutcome is what approveDevice did to the device.
+type approveOutcome int
+
+const (
+	// approveUnchanged means the device was already in the requested state.
+	approveUnchanged approveOutcome = iota
+	// approveApplied means the device was changed.
+	approveApplied
+	// approveDryRun means the device would have changed, but cfg.DryRun only
+	// logged the writes, so callers must not record it as approved.
+	approveDryRun
+)
+
+// approveDevice validates req.Tags and applies them to the device, authorizing
+// it first when requested and setting its key expiry when req.KeyExpiryDisabled
+// is set. If the device is already in the requested state it is left untouched
+// and the outcome is approveUnchanged, so a repeated approve is harmless.
+func approveDevice(ctx context.Context, devices DevicesClient, policy PolicyClient, cfg Config, locks *deviceLocks, deviceID string, req ApproveRequest) (device Device, outcome approveOutcome, err error) {
+	if len(req.Tags) == 0 {
+		return Device{}, approveUnchanged, &badRequestError{err: errNoTags}
+	}
+
+	unlock := locks.lock(deviceID)
+	defer unlock()
+
+	// Validate that all requested tags are in the available tags list
+	availableTags, err := withRetry(ctx, func() ([]string, error) {
+		return policy.GetAvailableTags(ctx)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get available tags for validation", "error", err)
+		return Device{}, approveUnchanged, err
+	}
+
+	if err := validateTags(req.Tags, availableTags, cfg); err != nil {
+		slog.ErrorContext(ctx, "Invalid tags requested", "tags", req.Tags, "error", err)
+		return Device{}, approveUnchanged, &badRequestError{err: err}
+	}
+
+	device, err = withRetry(ctx, func() (Device, error) {
+		return devices.Get(ctx, deviceID)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get device", "deviceID", deviceID, "error", err)
+		return Device{}, approveUnchanged, err
+	}
+
+	slog.InfoContext(ctx, "Approve requested", "deviceID", deviceID, "tags", req.Tags)
+
+	keyExpiryMatches := req.KeyExpiryDisabled == nil || *req.KeyExpiryDisabled == device.KeyExpiryDisabled
+	if hasExactTags(device, req.Tags) && (device.Authorized || !req.Authorize) && keyExpiryMatches {
+		slog.InfoContext(ctx, "Device already approved with requested tags", "deviceID", deviceID, "tags", req.Tags)
+		return device, approveUnchanged, nil
+	}
+
+	if !device.Authorized && req.Authorize {
+		_, err = withRetry(ctx, func() (struct{}, error) {
+			return struct{}{}, devices.Authorize(ctx, deviceID)
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to authorize device", "deviceID", deviceID, "error", err)
+			return Device{}, approveUnchanged, err
+		}
+		slog.InfoContext(ctx, "Authorized device", "deviceID", deviceID)
+	}
+
+	_, err = withRetry(ctx, func() (struct{}, error) {
+		return struct{}{}, devices.SetTags(ctx, deviceID, req.Tags)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to set tags", "deviceID", deviceID, "error", err)
+		return Device{}, approveUnchanged, err
+	}
+
+	if !keyExpiryMatches {
+		_, err = withRetry(ctx, func() (struct{}, error) {
+			return struct{}{}, devices.SetKeyExpiry(ctx, deviceID, *req.KeyExpiryDisabled)
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to set key expiry", "deviceID", deviceID, "error", err)
+			return Device{}, approveUnchanged, err
+		}
+		slog.InfoContext(ctx, "Set key expiry", "deviceID", deviceID, "keyExpiryDisabled", *req.KeyExpiryDisabled)
+	}
+
+	if cfg.DryRun {
+		return device, approveDryRun, nil
+	}
+	return device, approveApplied, nil
+}
+
+// hasExactTags reports whether the device's tags are the same set as tags.
+func hasExactTags(device Device, tags []string) bool {
+	if len(device.Tags) != len(tags) {
+		return false
+	}
+	for _, t := range tags {
+		if !slices.Contains(device.Tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBadRequest reports whether err was caused by an invalid approve request.
+func isBadRequest(err error) bool {
+	var badRequest *badRequestError
+	return errors.As(err, &badRequest)
+}
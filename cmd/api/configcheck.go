@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// redactedValue replaces a secret in printed config.
+const redactedValue = "REDACTED"
+
+// configCheckRequested reports whether the binary should only validate its
+// configuration, via the -check flag or CONFIG_CHECK=true.
+func configCheckRequested() bool {
+	check := flag.Bool("check", false, "validate configuration, print it with secrets redacted, and exit")
+	flag.Parse()
+	return *check || os.Getenv("CONFIG_CHECK") == "true"
+}
+
+// redacted returns a copy of the config with secrets masked.
+func (c Config) redacted() Config {
+	if c.APIKey != "" {
+		c.APIKey = redactedValue
+	}
+	if c.ReadOnlyAPIKey != "" {
+		c.ReadOnlyAPIKey = redactedValue
+	}
+	if c.HMACSecret != "" {
+		c.HMACSecret = redactedValue
+	}
+	if c.MetricsToken != "" {
+		c.MetricsToken = redactedValue
+	}
+	c.EventSinkURL = redactURL(c.EventSinkURL)
+	return c
+}
+
+// printConfig writes each config field as "Name: value" with secrets masked.
+func printConfig(w io.Writer, cfg Config) {
+	v := reflect.ValueOf(cfg.redacted())
+	for i := range v.NumField() {
+		fmt.Fprintf(w, "%s: %v\n", v.Type().Field(i).Name, v.Field(i))
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrintConfig_RedactsAPIKey(t *testing.T) {
+	var out strings.Builder
+
+	printConfig(&out, Config{Tailnet: "example.com", APIKey: "tskey-api-secret"})
+
+	if strings.Contains(out.String(), "tskey-api-secret") {
+		t.Errorf("API key was printed:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "APIKey: "+redactedValue) || !strings.Contains(out.String(), "Tailnet: example.com") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
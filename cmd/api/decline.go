@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// Decline reasons accepted by POST /decline/{deviceID}. The set is fixed so
+// declinesTotal stays aggregatable.
+const (
+	declineReasonSpam             = "spam"
+	declineReasonDuplicate        = "duplicate"
+	declineReasonUnauthorizedUser = "unauthorized_user"
+	declineReasonOther            = "other"
+)
+
+var declineReasons = []string{
+	declineReasonSpam,
+	declineReasonDuplicate,
+	declineReasonUnauthorizedUser,
+	declineReasonOther,
+}
+
+// DeclineRequest is the optional body of POST /decline/{deviceID}.
+type DeclineRequest struct {
+	// Reason is one of declineReasons. It defaults to "other".
+	Reason string `json:"reason,omitempty"`
+	// Actor optionally names who declined the device, for GET /history.
+	Actor string `json:"actor,omitempty"`
+}
+
+// decodeDeclineRequest reads a DeclineRequest from body. An empty body is
+// accepted for clients that do not send a reason. A body over the
+// http.MaxBytesReader limit returns the *http.MaxBytesError.
+func decodeDeclineRequest(body io.Reader) (DeclineRequest, error) {
+	var req DeclineRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		if isBodyTooLarge(err) {
+			return DeclineRequest{}, err
+		}
+		return DeclineRequest{}, errors.New("invalid request body")
+	}
+	if req.Reason == "" {
+		req.Reason = declineReasonOther
+	}
+	if !slices.Contains(declineReasons, req.Reason) {
+		return DeclineRequest{}, fmt.Errorf("invalid decline reason: %s", req.Reason)
+	}
+	return req, nil
+}
+
+func logDeviceDeclined(ctx context.Context, deviceID, reason string, suppressFor time.Duration) {
+	slog.InfoContext(ctx, "device_declined",
+		"device_id", deviceID,
+		"reason", reason,
+		"suppress_for", suppressFor,
+	)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeDeclineRequest(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{body: "", want: declineReasonOther},
+		{body: `{}`, want: declineReasonOther},
+		{body: `{"reason": "duplicate"}`, want: declineReasonDuplicate},
+		{body: `{"reason": "unauthorized_user"}`, want: declineReasonUnauthorizedUser},
+		{body: `{"reason": "because"}`, wantErr: true},
+		{body: `not json`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		req, err := decodeDeclineRequest(strings.NewReader(tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("body %q: expected an error, got reason %q", tt.body, req.Reason)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("body %q: unexpected error: %v", tt.body, err)
+			continue
+		}
+		if req.Reason != tt.want {
+			t.Errorf("body %q: expected reason %q, got %q", tt.body, tt.want, req.Reason)
+		}
+	}
+}
+
+func TestDecodeDeclineRequest_RejectsOversizedBody(t *testing.T) {
+	body := `{"reason": "other", "padding": "` + strings.Repeat("x", 1024) + `"}`
+	rec := httptest.NewRecorder()
+
+	_, err := decodeDeclineRequest(http.MaxBytesReader(rec, http.NoBody, 0))
+	if err != nil {
+		t.Fatalf("empty body: unexpected error: %v", err)
+	}
+
+	_, err = decodeDeclineRequest(http.MaxBytesReader(rec, httptest.NewRequest(http.MethodPost, "/decline/1", strings.NewReader(body)).Body, 512))
+	if !isBodyTooLarge(err) {
+		t.Fatalf("expected a body too large error, got %v", err)
+	}
+
+	writeBodyTooLarge(rec, 512)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Event types published to the event sink.
+const (
+	eventDeviceApproved = "device_approved"
+	eventDeviceDeclined = "device_declined"
+)
+
+// eventBufferSize is how many events may wait for a slow or unreachable sink
+// before new ones are dropped.
+const eventBufferSize = 256
+
+// Event is the JSON payload published on each approve or decline.
+type Event struct {
+	Type     string    `json:"type"`
+	DeviceID string    `json:"device_id"`
+	Name     string    `json:"name,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Actor    string    `json:"actor,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// EventSink publishes approval events to downstream systems.
+type EventSink interface {
+	Publish(event Event) error
+	Close() error
+}
+
+// nopEventSink discards events. It is used when EVENT_SINK_URL is not set.
+type nopEventSink struct{}
+
+func (nopEventSink) Publish(Event) error { return nil }
+func (nopEventSink) Close() error        { return nil }
+
+// natsEventSink publishes events as JSON to a NATS subject.
+type natsEventSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// newNATSEventSink connects to the NATS server at sinkURL. An unreachable
+// server does not fail startup; the client keeps reconnecting in the background.
+func newNATSEventSink(sinkURL, subject string) (*natsEventSink, error) {
+	conn, err := nats.Connect(sinkURL,
+		nats.Name("tailscale-approval-api"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &natsEventSink{conn: conn, subject: subject}, nil
+}
+
+func (n *natsEventSink) Publish(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return n.conn.Publish(n.subject, data)
+}
+
+func (n *natsEventSink) Close() error {
+	return n.conn.Drain()
+}
+
+// bufferedEventSink hands events to the wrapped sink from a background
+// goroutine so handlers never wait on the broker. Events that do not fit in
+// the buffer, or that the sink fails to publish, are dropped and counted.
+type bufferedEventSink struct {
+	sink   EventSink
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newBufferedEventSink(sink EventSink, size int) *bufferedEventSink {
+	b := &bufferedEventSink{
+		sink:   sink,
+		events: make(chan Event, size),
+		done:   make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *bufferedEventSink) run() {
+	defer close(b.done)
+	for event := range b.events {
+		if err := b.sink.Publish(event); err != nil {
+			eventsDroppedTotal.Inc()
+			slog.Warn("Failed to publish event, dropping it", "type", event.Type, "deviceID", event.DeviceID, "error", err)
+		}
+	}
+}
+
+// Publish queues the event without blocking. It always returns nil.
+func (b *bufferedEventSink) Publish(event Event) error {
+	select {
+	case b.events <- event:
+	default:
+		eventsDroppedTotal.Inc()
+		slog.Warn("Event buffer full, dropping event", "type", event.Type, "deviceID", event.DeviceID)
+	}
+	return nil
+}
+
+// Close publishes the queued events, then closes the wrapped sink. Publish
+// must not be called after Close.
+func (b *bufferedEventSink) Close() error {
+	b.once.Do(func() { close(b.events) })
+	<-b.done
+	return b.sink.Close()
+}
+
+// newEventSink returns the sink configured by EVENT_SINK_URL, or a no-op sink.
+func newEventSink(cfg Config) (EventSink, error) {
+	if cfg.EventSinkURL == "" {
+		return nopEventSink{}, nil
+	}
+	sink, err := newNATSEventSink(cfg.EventSinkURL, cfg.EventSinkSubject)
+	if err != nil {
+		return nil, err
+	}
+	return newBufferedEventSink(sink, eventBufferSize), nil
+}
+
+// validateEventSinkURL checks that EVENT_SINK_URL names a supported broker.
+func validateEventSinkURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("EVENT_SINK_URL is not a valid URL: %w", err)
+	}
+	if parsed.Scheme != "nats" && parsed.Scheme != "tls" {
+		return fmt.Errorf("EVENT_SINK_URL must be a nats:// or tls:// URL, got scheme %q", parsed.Scheme)
+	}
+	return nil
+}
+
+// redactURL masks the password in a URL, leaving other values unchanged.
+func redactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.User == nil {
+		return value
+	}
+	return parsed.Redacted()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// blockingEventSink holds every Publish until release is closed.
+type blockingEventSink struct {
+	started   chan struct{}
+	release   chan struct{}
+	published chan Event
+}
+
+func (b *blockingEventSink) Publish(event Event) error {
+	b.started <- struct{}{}
+	<-b.release
+	b.published <- event
+	return nil
+}
+
+func (b *blockingEventSink) Close() error { return nil }
+
+func TestBufferedEventSink_DropsWhenBufferIsFull(t *testing.T) {
+	sink := &blockingEventSink{started: make(chan struct{}, 10), release: make(chan struct{}), published: make(chan Event, 10)}
+	buffered := newBufferedEventSink(sink, 1)
+	before := testutil.ToFloat64(eventsDroppedTotal)
+
+	// The worker blocks publishing the first event, the second fills the
+	// buffer and the third is dropped.
+	buffered.Publish(Event{DeviceID: "1"})
+	<-sink.started
+	buffered.Publish(Event{DeviceID: "2"})
+	buffered.Publish(Event{DeviceID: "3"})
+
+	if got := testutil.ToFloat64(eventsDroppedTotal) - before; got != 1 {
+		t.Errorf("expected 1 dropped event, got %v", got)
+	}
+
+	close(sink.release)
+	if err := buffered.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(sink.published)
+
+	var ids []string
+	for event := range sink.published {
+		ids = append(ids, event.DeviceID)
+	}
+	if len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+		t.Errorf("expected events 1 and 2 to be published, got %v", ids)
+	}
+}
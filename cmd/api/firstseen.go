@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// firstSeenTTL evicts devices that were never approved or declined, so the
+// map does not grow without bound.
+const firstSeenTTL = 7 * 24 * time.Hour
+
+// firstSeenTracker remembers when each device first appeared in
+// /pending-devices, to measure time-to-approve.
+type firstSeenTracker struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	now  func() time.Time
+}
+
+func newFirstSeenTracker() *firstSeenTracker {
+	return &firstSeenTracker{
+		seen: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// record notes the devices as seen now unless they were already seen, and
+// evicts entries older than firstSeenTTL.
+func (t *firstSeenTracker) record(devices []PendingDevice) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for id, seenAt := range t.seen {
+		if now.Sub(seenAt) > firstSeenTTL {
+			delete(t.seen, id)
+		}
+	}
+	for _, d := range devices {
+		if _, ok := t.seen[d.ID]; !ok {
+			t.seen[d.ID] = now
+		}
+	}
+}
+
+// seenAt returns when deviceID was first seen.
+func (t *firstSeenTracker) seenAt(deviceID string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seenAt, ok := t.seen[deviceID]
+	return seenAt, ok
+}
+
+// take forgets deviceID and returns how long ago it was first seen.
+func (t *firstSeenTracker) take(deviceID string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seenAt, ok := t.seen[deviceID]
+	if !ok {
+		return 0, false
+	}
+	delete(t.seen, deviceID)
+	return t.now().Sub(seenAt), true
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFirstSeenTracker_MeasuresFromFirstAppearance(t *testing.T) {
+	now := time.Now()
+	tracker := newFirstSeenTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.record([]PendingDevice{{ID: "1"}})
+	now = now.Add(30 * time.Minute)
+	tracker.record([]PendingDevice{{ID: "1"}})
+	now = now.Add(30 * time.Minute)
+
+	elapsed, ok := tracker.take("1")
+	if !ok {
+		t.Fatal("expected device to be tracked")
+	}
+	if elapsed != time.Hour {
+		t.Errorf("expected 1h since first seen, got %s", elapsed)
+	}
+	if _, ok := tracker.take("1"); ok {
+		t.Error("expected device to be evicted after take")
+	}
+}
+
+func TestFirstSeenTracker_EvictsAfterTTL(t *testing.T) {
+	now := time.Now()
+	tracker := newFirstSeenTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.record([]PendingDevice{{ID: "1"}})
+	now = now.Add(firstSeenTTL + time.Minute)
+	tracker.record(nil)
+
+	if _, ok := tracker.take("1"); ok {
+		t.Error("expected stale device to be evicted")
+	}
+}
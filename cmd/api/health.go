@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tailscaleHealth remembers the outcome of recent Tailscale API calls so
+// /readyz can report on them without calling Tailscale on every probe.
+type tailscaleHealth struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+}
+
+var tailscaleStatus = &tailscaleHealth{}
+
+// readinessProbeInterval is how often probeTailscale checks Tailscale when no
+// request has succeeded in that time.
+const readinessProbeInterval = 30 * time.Second
+
+// record notes the result of a Tailscale call. Errors caused by the request
+// rather than by Tailscale say nothing about its health and are ignored.
+func (h *tailscaleHealth) record(err error, now time.Time) {
+	if err != nil && !isTailscaleHealthError(err) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		h.lastSuccess = now
+		return
+	}
+	h.lastFailure = now
+	h.lastError = err.Error()
+}
+
+// isTailscaleHealthError reports whether err means Tailscale cannot currently
+// serve us. Client errors (4xx) other than 401, 403 and 429 come from a bad
+// request, such as an unknown device or an invalid tag, and cancelled or
+// timed-out callers only say the caller gave up.
+func isTailscaleHealthError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch status := apiStatusCode(err); {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden, status == http.StatusTooManyRequests:
+		return true
+	case status >= 400 && status < 500:
+		return false
+	}
+	return true
+}
+
+// needsProbe reports whether no Tailscale call has succeeded within interval.
+func (h *tailscaleHealth) needsProbe(now time.Time, interval time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return now.Sub(h.lastSuccess) >= interval
+}
+
+// probeTailscale reads the ACL tags every interval while no request has
+// succeeded in that time, so /readyz recovers once Tailscale does even when
+// the failing readiness has stopped all traffic to the API.
+func probeTailscale(ctx context.Context, h *tailscaleHealth, policy PolicyClient, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !h.needsProbe(time.Now(), interval) {
+			continue
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, interval)
+		_, err := policy.GetAvailableTags(probeCtx)
+		cancel()
+		if err != nil {
+			slog.WarnContext(ctx, "Tailscale readiness probe failed", "error", err)
+		}
+		h.record(err, time.Now())
+	}
+}
+
+// ReadyzResponse is the body of GET /readyz.
+type ReadyzResponse struct {
+	Tailscale            string    `json:"tailscale"`
+	LastTailscaleSuccess time.Time `json:"last_tailscale_success,omitzero"`
+	LastTailscaleError   string    `json:"last_tailscale_error,omitempty"`
+}
+
+// readiness reports "fail" when the most recent Tailscale call failed.
+func (h *tailscaleHealth) readiness() (ReadyzResponse, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ok := h.lastFailure.IsZero() || h.lastSuccess.After(h.lastFailure)
+	resp := ReadyzResponse{Tailscale: "ok", LastTailscaleSuccess: h.lastSuccess}
+	if !ok {
+		resp.Tailscale = "fail"
+		resp.LastTailscaleError = h.lastError
+	}
+	return resp, ok
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTailscaleHealth_ReportsMostRecentOutcome(t *testing.T) {
+	h := &tailscaleHealth{}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if resp, ok := h.readiness(); !ok || resp.Tailscale != "ok" {
+		t.Errorf("expected ok before any call, got %+v", resp)
+	}
+
+	h.record(errors.New("401 unauthorized"), now)
+	resp, ok := h.readiness()
+	if ok || resp.Tailscale != "fail" || resp.LastTailscaleError != "401 unauthorized" {
+		t.Errorf("expected fail after an error, got %+v", resp)
+	}
+
+	h.record(nil, now.Add(time.Minute))
+	resp, ok = h.readiness()
+	if !ok || resp.Tailscale != "ok" || !resp.LastTailscaleSuccess.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected ok after a success, got %+v", resp)
+	}
+}
+
+func TestTailscaleHealth_IgnoresRequestErrors(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict} {
+		client := newFakeTailscale(t, &fakeTailscale{status: status})
+		_, err := client.List(context.Background())
+
+		h := &tailscaleHealth{}
+		h.record(err, now)
+		if _, ok := h.readiness(); !ok {
+			t.Errorf("status %d: expected readiness to ignore the error", status)
+		}
+	}
+
+	h := &tailscaleHealth{}
+	h.record(context.DeadlineExceeded, now)
+	if _, ok := h.readiness(); !ok {
+		t.Error("expected readiness to ignore a caller deadline")
+	}
+
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusBadGateway} {
+		client := newFakeTailscale(t, &fakeTailscale{status: status})
+		_, err := client.List(context.Background())
+
+		h := &tailscaleHealth{}
+		h.record(err, now)
+		if _, ok := h.readiness(); ok {
+			t.Errorf("status %d: expected readiness to fail", status)
+		}
+	}
+}
+
+func TestProbeTailscale_RecoversReadiness(t *testing.T) {
+	h := &tailscaleHealth{}
+	h.record(errors.New("503 unavailable"), time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go probeTailscale(ctx, h, &mockPolicyClient{}, 10*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := h.readiness(); ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("expected the probe to restore readiness")
+}
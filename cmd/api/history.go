@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+)
+
+// historySize is how many recent approve and decline actions GET /history keeps.
+const historySize = 100
+
+// actionHistory is an in-memory ring buffer of the most recent approve and
+// decline events. It is lost on restart; EVENT_SINK_URL is the durable record.
+type actionHistory struct {
+	mu     sync.Mutex
+	events []Event
+	next   int // index the next event is written to once events is full
+}
+
+func newActionHistory() *actionHistory {
+	return &actionHistory{events: make([]Event, 0, historySize)}
+}
+
+// add records event, overwriting the oldest one when the buffer is full.
+func (h *actionHistory) add(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.events) < historySize {
+		h.events = append(h.events, event)
+		return
+	}
+	h.events[h.next] = event
+	h.next = (h.next + 1) % historySize
+}
+
+// recent returns up to n events, newest first.
+func (h *actionHistory) recent(n int) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n = min(n, len(h.events))
+	result := make([]Event, 0, n)
+	for j := range n {
+		// The newest event sits just before next (or at the end before the buffer fills).
+		idx := (h.next - 1 - j + 2*len(h.events)) % len(h.events)
+		result = append(result, h.events[idx])
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestActionHistory_RecentIsNewestFirst(t *testing.T) {
+	history := newActionHistory()
+	for _, id := range []string{"1", "2", "3"} {
+		history.add(Event{DeviceID: id})
+	}
+
+	got := history.recent(2)
+	if len(got) != 2 || got[0].DeviceID != "3" || got[1].DeviceID != "2" {
+		t.Fatalf("expected devices 3 then 2, got %+v", got)
+	}
+	if got := history.recent(10); len(got) != 3 {
+		t.Errorf("expected all 3 events, got %d", len(got))
+	}
+}
+
+func TestActionHistory_DropsOldestWhenFull(t *testing.T) {
+	history := newActionHistory()
+	for j := range historySize + 5 {
+		history.add(Event{DeviceID: strconv.Itoa(j)})
+	}
+
+	got := history.recent(historySize + 5)
+	if len(got) != historySize {
+		t.Fatalf("expected %d events, got %d", historySize, len(got))
+	}
+	if got[0].DeviceID != strconv.Itoa(historySize+4) {
+		t.Errorf("expected newest event first, got %s", got[0].DeviceID)
+	}
+	if last := got[len(got)-1].DeviceID; last != "5" {
+		t.Errorf("expected oldest kept event to be 5, got %s", last)
+	}
+}
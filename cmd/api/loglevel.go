@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// logLevel is the level of the default logger. SIGUSR1 toggles it between
+// info and debug so a live process can be debugged without a restart.
+var logLevel = new(slog.LevelVar)
+
+// watchLogLevelSignal toggles logLevel on every SIGUSR1.
+func watchLogLevelSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	go func() {
+		for range sig {
+			toggleLogLevel()
+		}
+	}()
+}
+
+// toggleLogLevel switches logLevel between info and debug and returns the new level.
+func toggleLogLevel() slog.Level {
+	level := slog.LevelDebug
+	if logLevel.Level() == slog.LevelDebug {
+		level = slog.LevelInfo
+	}
+	logLevel.Set(level)
+	slog.Info("Log level changed", "level", level.String())
+	return level
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestToggleLogLevel_SwitchesBetweenInfoAndDebug(t *testing.T) {
+	defer logLevel.Set(slog.LevelInfo)
+
+	if got := toggleLogLevel(); got != slog.LevelDebug {
+		t.Errorf("first toggle: expected debug, got %v", got)
+	}
+	if got := toggleLogLevel(); got != slog.LevelInfo {
+		t.Errorf("second toggle: expected info, got %v", got)
+	}
+}
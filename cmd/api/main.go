@@ -4,54 +4,181 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	tsclient "github.com/tailscale/tailscale-client-go/v2"
 )
 
 type Config struct {
-	Tailnet  string
-	APIKey   string
-	HTTPPort string
+	Tailnet string
+	APIKey  string
+	// ReadOnlyAPIKey, when set, is used for listing devices and reading the
+	// ACL, leaving APIKey for writes only.
+	ReadOnlyAPIKey string
+	// DryRun logs approvals instead of applying them. It is forced on when
+	// only ReadOnlyAPIKey is set.
+	DryRun           bool
+	HTTPPort         string
+	MaxTagsPerDevice int
+	AllowedPrefixes  []string
+	// UnlistedTagPatterns are globs for tags that may be applied even when
+	// they are not yet listed in the ACL.
+	UnlistedTagPatterns []string
+	// ForbiddenTags may never be applied through the API, even if the ACL allows it.
+	ForbiddenTags  []string
+	HandlerTimeout time.Duration
+	SkipScopeCheck bool
+	// MaxRequestBytes caps the size of JSON request bodies.
+	MaxRequestBytes int64
+	// DeclineSuppressTTL hides declined devices from /pending-devices for this long.
+	DeclineSuppressTTL time.Duration
+	// TagsSourceURL, when set, supplies the available tags from a remote catalog
+	// instead of (or, with TagsSourceMode "merge", in addition to) the ACL.
+	TagsSourceURL  string
+	TagsSourceMode string
+	TagsSourceTTL  time.Duration
+	// TagsFallback is offered and accepted when the available tags cannot be
+	// read. Empty makes such a failure fail the request as before.
+	TagsFallback []string
+	// UserTagRules maps a device owner (or "@domain") to tags suggested for
+	// that owner's devices.
+	UserTagRules map[string][]string
+	// EventSinkURL, when set, is a NATS server that receives an event on each
+	// approve and decline, published to EventSinkSubject.
+	EventSinkURL     string
+	EventSinkSubject string
+	// TLSCertFile and TLSKeyFile serve the API over HTTPS. TLSClientCAFile
+	// additionally requires clients to present a certificate signed by it;
+	// with HMACSecret also set, the signed routes accept either.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	// MetricsToken, when set, is the bearer token required to scrape /metrics.
+	MetricsToken string
+	// HMACSecret, when set, requires a fresh X-Signature over the method,
+	// path and body on the approve, decline and snooze endpoints, unless the
+	// request has a verified client certificate.
+	HMACSecret string
 }
 
 type Device struct {
-	ID         string   `json:"id"`
-	Name       string   `json:"name"`
-	Authorized bool     `json:"authorized"`
-	Tags       []string `json:"tags"`
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	ShortName  string    `json:"short_name"`
+	Hostname   string    `json:"hostname"`
+	User       string    `json:"user"`
+	Authorized bool      `json:"authorized"`
+	Tags       []string  `json:"tags"`
+	NodeKey    string    `json:"node_key"`
+	OS         string    `json:"os"`
+	Addresses  []string  `json:"addresses"`
+	LastSeen   time.Time `json:"last_seen,omitzero"`
+	Created    time.Time `json:"created,omitzero"`
+	// KeyExpiryDisabled is true when the device's node key never expires.
+	KeyExpiryDisabled bool `json:"key_expiry_disabled"`
 }
 
 type PendingDevice struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ShortName  string `json:"short_name"`
+	Hostname   string `json:"hostname"`
+	User       string `json:"user"`
+	Authorized bool   `json:"authorized"`
+	NodeKey    string `json:"node_key"`
+	OS         string `json:"os"`
+	// Created is when the device joined the tailnet.
+	Created time.Time `json:"created,omitzero"`
+	// SuggestedTags are tags USER_TAG_RULES suggest for the device's owner.
+	SuggestedTags []string `json:"suggested_tags,omitempty"`
 }
 
 type PendingDevicesResponse struct {
 	PendingDevices []PendingDevice `json:"pending_devices"`
 }
 
+// PendingDeviceGroup is the pending devices owned by one user.
+type PendingDeviceGroup struct {
+	User    string          `json:"user"`
+	Devices []PendingDevice `json:"devices"`
+}
+
+type PendingDeviceGroupsResponse struct {
+	Groups []PendingDeviceGroup `json:"groups"`
+}
+
+type PendingDevicesCountResponse struct {
+	Count int `json:"count"`
+}
+
+type DevicesResponse struct {
+	Devices []Device `json:"devices"`
+}
+
 type TagsResponse struct {
 	Tags []string `json:"tags"`
 }
 
 type ApproveRequest struct {
 	Tags []string `json:"tags"`
+	// Authorize authorizes the device before tagging it if it is not authorized yet.
+	Authorize bool `json:"authorize,omitempty"`
+	// KeyExpiryDisabled, when set, also sets whether the device's key expires.
+	KeyExpiryDisabled *bool `json:"key_expiry_disabled,omitempty"`
+	// Actor optionally names who approved the device, for GET /history.
+	Actor string `json:"actor,omitempty"`
+}
+
+type ApproveResponse struct {
+	// DryRun is set when dry-run mode only logged the changes instead of making them.
+	DryRun bool `json:"dry_run"`
+}
+
+type HistoryResponse struct {
+	Actions []Event `json:"actions"`
+}
+
+// PendingFilter narrows the devices returned by getPendingDevices.
+type PendingFilter struct {
+	// NamePrefix keeps only devices whose name starts with this prefix.
+	NamePrefix string
+	// State selects which devices are pending: pendingStateUntagged (default)
+	// or pendingStateUnauthorized.
+	State string
+	// Exclude, if set, hides devices for which it returns true.
+	Exclude func(deviceID string) bool
 }
 
+const (
+	// pendingStateUntagged selects authorized devices without tags.
+	pendingStateUntagged = "untagged"
+	// pendingStateUnauthorized selects devices waiting for authorization.
+	pendingStateUnauthorized = "unauthorized"
+)
+
 type DevicesClient interface {
 	List(ctx context.Context) ([]Device, error)
+	Get(ctx context.Context, deviceID string) (Device, error)
 	SetTags(ctx context.Context, deviceID string, tags []string) error
+	Authorize(ctx context.Context, deviceID string) error
+	SetKeyExpiry(ctx context.Context, deviceID string, disabled bool) error
 }
 
 type PolicyClient interface {
 	GetAvailableTags(ctx context.Context) ([]string, error)
+	GetTagOwners(ctx context.Context) (map[string][]string, error)
 }
 
 type tailscaleClient struct {
@@ -65,43 +192,98 @@ func (c *tailscaleClient) List(ctx context.Context) ([]Device, error) {
 	}
 	result := make([]Device, len(devices))
 	for i, d := range devices {
-		result[i] = Device{
-			ID:         d.ID,
-			Name:       d.Name,
-			Authorized: d.Authorized,
-			Tags:       d.Tags,
-		}
+		result[i] = toDevice(d)
 	}
 	return result, nil
 }
 
+func (c *tailscaleClient) Get(ctx context.Context, deviceID string) (Device, error) {
+	d, err := c.client.Devices().Get(ctx, deviceID)
+	if err != nil {
+		return Device{}, err
+	}
+	return toDevice(*d), nil
+}
+
+func toDevice(d tsclient.Device) Device {
+	// Always return a non-nil slice so the tags encode as [] rather than null.
+	tags := d.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return Device{
+		ID:         d.ID,
+		Name:       d.Name,
+		ShortName:  shortName(d.Name),
+		Hostname:   d.Hostname,
+		User:       d.User,
+		Authorized: d.Authorized,
+		Tags:       tags,
+		NodeKey:    d.NodeKey,
+		OS:         d.OS,
+		Addresses:  d.Addresses,
+		LastSeen:   d.LastSeen.Time,
+		Created:    d.Created.Time,
+
+		KeyExpiryDisabled: d.KeyExpiryDisabled,
+	}
+}
+
 func (c *tailscaleClient) SetTags(ctx context.Context, deviceID string, tags []string) error {
 	return c.client.Devices().SetTags(ctx, deviceID, tags)
 }
 
+func (c *tailscaleClient) Authorize(ctx context.Context, deviceID string) error {
+	return c.client.Devices().SetAuthorized(ctx, deviceID, true)
+}
+
+func (c *tailscaleClient) SetKeyExpiry(ctx context.Context, deviceID string, disabled bool) error {
+	return c.client.Devices().SetKey(ctx, deviceID, tsclient.DeviceKey{KeyExpiryDisabled: disabled})
+}
+
 func (c *tailscaleClient) GetAvailableTags(ctx context.Context) ([]string, error) {
-	acl, err := c.client.PolicyFile().Get(ctx)
+	owners, err := c.GetTagOwners(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	var tags []string
-	for tag := range acl.TagOwners {
+	for tag := range owners {
 		tags = append(tags, tag)
 	}
 	slices.Sort(tags)
 	return tags, nil
 }
 
+// GetTagOwners returns the ACL's tagOwners mapping of tag to owners.
+func (c *tailscaleClient) GetTagOwners(ctx context.Context) (map[string][]string, error) {
+	acl, err := c.client.PolicyFile().Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return acl.TagOwners, nil
+}
+
+// loadConfig reads the configuration from the environment. All problems are
+// collected and returned together so they can be fixed in one pass.
 func loadConfig() (Config, error) {
+	var errs []error
+
 	tailnet := os.Getenv("TAILSCALE_TAILNET")
 	if tailnet == "" {
-		return Config{}, errors.New("TAILSCALE_TAILNET is required")
+		errs = append(errs, errors.New("TAILSCALE_TAILNET is required"))
+	}
+
+	// ALLOWED_TAILNET is a safety interlock against pointing a deployment at the wrong tailnet.
+	if allowedTailnet := os.Getenv("ALLOWED_TAILNET"); allowedTailnet != "" && tailnet != allowedTailnet {
+		errs = append(errs, fmt.Errorf("TAILSCALE_TAILNET %q does not match ALLOWED_TAILNET %q", tailnet, allowedTailnet))
 	}
 
 	apiKey := os.Getenv("TAILSCALE_API_KEY")
-	if apiKey == "" {
-		return Config{}, errors.New("TAILSCALE_API_KEY is required")
+	readOnlyAPIKey := os.Getenv("TAILSCALE_API_KEY_READONLY") // optional: empty = TAILSCALE_API_KEY for reads too
+	if apiKey == "" && readOnlyAPIKey == "" {
+		errs = append(errs, errors.New("TAILSCALE_API_KEY or TAILSCALE_API_KEY_READONLY is required"))
 	}
 
 	httpPort := os.Getenv("HTTP_PORT")
@@ -109,15 +291,159 @@ func loadConfig() (Config, error) {
 		httpPort = "8080"
 	}
 
+	maxTagsPerDevice := 0
+	if maxTagsStr := os.Getenv("MAX_TAGS_PER_DEVICE"); maxTagsStr != "" {
+		parsed, err := strconv.Atoi(maxTagsStr)
+		if err != nil || parsed < 0 {
+			errs = append(errs, errors.New("MAX_TAGS_PER_DEVICE must be a non-negative integer"))
+		}
+		maxTagsPerDevice = parsed
+	}
+
+	allowedPrefixes := splitList(os.Getenv("ALLOWED_TAG_PREFIXES")) // optional: empty = no prefix restriction
+
+	forbiddenTags := splitList(os.Getenv("FORBIDDEN_TAGS")) // optional: empty = no tags forbidden
+
+	unlistedTagPatterns := splitList(os.Getenv("ALLOW_UNLISTED_TAGS")) // optional: empty = only tags from the ACL
+	for _, pattern := range unlistedTagPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("ALLOW_UNLISTED_TAGS has an invalid pattern %q", pattern))
+		}
+	}
+
+	handlerTimeout := 45 * time.Second
+	if handlerTimeoutStr := os.Getenv("HANDLER_TIMEOUT"); handlerTimeoutStr != "" {
+		parsed, err := time.ParseDuration(handlerTimeoutStr)
+		if err != nil || parsed <= 0 {
+			errs = append(errs, errors.New("HANDLER_TIMEOUT must be a positive duration (e.g., 45s, 1m)"))
+		}
+		handlerTimeout = parsed
+	}
+
+	maxRequestBytes := int64(64 << 10)
+	if maxBytesStr := os.Getenv("MAX_REQUEST_BYTES"); maxBytesStr != "" {
+		parsed, err := strconv.ParseInt(maxBytesStr, 10, 64)
+		if err != nil || parsed <= 0 {
+			errs = append(errs, errors.New("MAX_REQUEST_BYTES must be a positive integer"))
+		}
+		maxRequestBytes = parsed
+	}
+
+	declineSuppressTTL := 24 * time.Hour
+	if ttlStr := os.Getenv("DECLINE_SUPPRESS_TTL"); ttlStr != "" {
+		parsed, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			errs = append(errs, errors.New("DECLINE_SUPPRESS_TTL must be a valid duration (e.g., 24h, 0 to disable)"))
+		}
+		declineSuppressTTL = parsed
+	}
+
+	userTagRules, err := parseUserTagRules(os.Getenv("USER_TAG_RULES")) // optional: empty = no suggestions
+	if err != nil {
+		errs = append(errs, fmt.Errorf("USER_TAG_RULES %w", err))
+	}
+
+	tagsSourceURL := os.Getenv("TAGS_SOURCE_URL") // optional: empty = tags from the ACL only
+
+	tagsSourceMode := os.Getenv("TAGS_SOURCE_MODE")
+	if tagsSourceMode == "" {
+		tagsSourceMode = tagsSourceReplace
+	}
+	if tagsSourceMode != tagsSourceReplace && tagsSourceMode != tagsSourceMerge {
+		errs = append(errs, errors.New("TAGS_SOURCE_MODE must be \"replace\" or \"merge\""))
+	}
+
+	tagsSourceTTL := 5 * time.Minute
+	if ttlStr := os.Getenv("TAGS_SOURCE_TTL"); ttlStr != "" {
+		parsed, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			errs = append(errs, errors.New("TAGS_SOURCE_TTL must be a valid duration (e.g., 5m, 1h)"))
+		}
+		tagsSourceTTL = parsed
+	}
+
+	tagsFallback := splitList(os.Getenv("TAGS_FALLBACK")) // optional: empty = tag reads fail while the ACL cannot be read
+	for _, tag := range tagsFallback {
+		if !strings.HasPrefix(tag, "tag:") {
+			errs = append(errs, fmt.Errorf("TAGS_FALLBACK contains %q, tags must start with \"tag:\"", tag))
+		}
+	}
+
+	eventSinkURL := os.Getenv("EVENT_SINK_URL") // optional: empty = no events published
+	if eventSinkURL != "" {
+		if err := validateEventSinkURL(eventSinkURL); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	eventSinkSubject := os.Getenv("EVENT_SINK_SUBJECT")
+	if eventSinkSubject == "" {
+		eventSinkSubject = "tailscale.approval.events"
+	}
+
+	tlsCertFile := os.Getenv("TLS_CERT_FILE") // optional: empty = plain HTTP
+	tlsKeyFile := os.Getenv("TLS_KEY_FILE")
+	tlsClientCAFile := os.Getenv("TLS_CLIENT_CA_FILE") // optional: empty = no client certificate required
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if tlsClientCAFile != "" && tlsCertFile == "" {
+		errs = append(errs, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE"))
+	}
+
+	metricsToken := os.Getenv("METRICS_TOKEN") // optional: empty = /metrics is open
+	hmacSecret := os.Getenv("HMAC_SECRET")     // optional: empty = unsigned approve/decline requests accepted
+
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+
 	return Config{
-		Tailnet:  tailnet,
-		APIKey:   apiKey,
-		HTTPPort: httpPort,
+		Tailnet:             tailnet,
+		APIKey:              apiKey,
+		ReadOnlyAPIKey:      readOnlyAPIKey,
+		DryRun:              apiKey == "",
+		HTTPPort:            httpPort,
+		MaxTagsPerDevice:    maxTagsPerDevice,
+		AllowedPrefixes:     allowedPrefixes,
+		UnlistedTagPatterns: unlistedTagPatterns,
+		ForbiddenTags:       forbiddenTags,
+		HandlerTimeout:      handlerTimeout,
+		SkipScopeCheck:      os.Getenv("SKIP_SCOPE_CHECK") == "true",
+		MaxRequestBytes:     maxRequestBytes,
+		DeclineSuppressTTL:  declineSuppressTTL,
+		TagsSourceURL:       tagsSourceURL,
+		TagsSourceMode:      tagsSourceMode,
+		TagsSourceTTL:       tagsSourceTTL,
+		TagsFallback:        tagsFallback,
+		UserTagRules:        userTagRules,
+		EventSinkURL:        eventSinkURL,
+		EventSinkSubject:    eventSinkSubject,
+		TLSCertFile:         tlsCertFile,
+		TLSKeyFile:          tlsKeyFile,
+		TLSClientCAFile:     tlsClientCAFile,
+		MetricsToken:        metricsToken,
+		HMACSecret:          hmacSecret,
 	}, nil
 }
 
+// splitList splits a comma-separated env value, trimming whitespace and
+// dropping empty entries.
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
 func main() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	slog.SetDefault(slog.New(&requestIDHandler{Handler: slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})}))
+	watchLogLevelSignal()
+
+	checkOnly := configCheckRequested()
 
 	cfg, err := loadConfig()
 	if err != nil {
@@ -125,16 +451,85 @@ func main() {
 		os.Exit(1)
 	}
 
-	client := &tailscaleClient{
-		client: &tsclient.Client{
-			Tailnet: cfg.Tailnet,
-			APIKey:  cfg.APIKey,
+	// In check mode, stop before any network calls.
+	if checkOnly {
+		printConfig(os.Stdout, cfg)
+		fmt.Println("Config OK")
+		return
+	}
+
+	readKey := cfg.APIKey
+	if cfg.ReadOnlyAPIKey != "" {
+		readKey = cfg.ReadOnlyAPIKey
+	}
+	client := &splitKeyClient{
+		tailscaleClient: &tailscaleClient{
+			client: &tsclient.Client{
+				Tailnet: cfg.Tailnet,
+				APIKey:  readKey,
+			},
 		},
 	}
+	switch {
+	case cfg.DryRun:
+		slog.Warn("Only TAILSCALE_API_KEY_READONLY is set; running in dry-run mode, approvals are logged but not applied")
+		client.writer = dryRunWriter{}
+	case cfg.ReadOnlyAPIKey != "":
+		slog.Info("Using TAILSCALE_API_KEY_READONLY for reads and TAILSCALE_API_KEY for writes")
+		client.writer = &tailscaleClient{
+			client: &tsclient.Client{
+				Tailnet: cfg.Tailnet,
+				APIKey:  cfg.APIKey,
+			},
+		}
+	default:
+		client.writer = client.tailscaleClient
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	if cfg.SkipScopeCheck {
+		slog.Info("Skipping API key scope check")
+	} else if err := checkAPIKeyScopes(ctx, client, client, cfg.DryRun); err != nil {
+		slog.Error("Tailscale API key is missing required scopes", "error", err)
+		os.Exit(1)
+	}
+
+	var policy PolicyClient = client
+	if cfg.TagsSourceURL != "" {
+		slog.Info("Using remote tag catalog", "url", cfg.TagsSourceURL, "mode", cfg.TagsSourceMode, "ttl", cfg.TagsSourceTTL)
+		policy = newRemoteTagsPolicy(client, cfg.TagsSourceURL, cfg.TagsSourceMode, cfg.TagsSourceTTL)
+	}
+	if len(cfg.TagsFallback) > 0 {
+		slog.Info("Using fallback tags when the available tags cannot be read", "tags", cfg.TagsFallback)
+		policy = &fallbackTagsPolicy{policy: policy, tags: cfg.TagsFallback}
+	}
+
+	// Probe the ACL directly, not through TAGS_FALLBACK, which would hide failures.
+	go probeTailscale(ctx, tailscaleStatus, client, readinessProbeInterval)
+
+	events, err := newEventSink(cfg)
+	if err != nil {
+		slog.Error("Failed to set up event sink", "error", err)
+		os.Exit(1)
+	}
+
+	// Devices declined recently are hidden from /pending-devices until the TTL passes.
+	declined := newExpiringSet()
+
+	// Devices snoozed through POST /snooze are hidden the same way until their snooze ends.
+	snoozed := newExpiringSet()
+	hidden := func(deviceID string) bool {
+		return declined.contains(deviceID) || snoozed.contains(deviceID)
+	}
+
+	// When each device first appeared as pending, for the time-to-approve histogram.
+	firstSeen := newFirstSeenTracker()
+
+	// Recent approve and decline actions, served by GET /history.
+	history := newActionHistory()
+
 	mux := http.NewServeMux()
 
 	// GET /healthz - Health check endpoint for Kubernetes probes.
@@ -144,111 +539,406 @@ func main() {
 		w.Write([]byte("ok"))
 	})
 
+	// GET /readyz - Readiness based on the most recent Tailscale API call, so
+	// probes never call Tailscale themselves. probeTailscale keeps it current
+	// while no request succeeds.
+	// Returns 200 with {"tailscale": "ok", ...}, or 503 with "fail" and the last error.
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := tailscaleStatus.readiness()
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	// GET /openapi.json - OpenAPI 3 description of these endpoints, for client
+	// generation and Swagger UI.
+	mux.HandleFunc("GET /openapi.json", serveOpenAPISpec)
+
+	// GET /metrics - Prometheus metrics.
+	// With METRICS_TOKEN set, requires "Authorization: Bearer <token>".
+	mux.Handle("GET /metrics", withBearerToken(promhttp.Handler(), cfg.MetricsToken))
+
 	// GET /pending-devices - Returns a list of Tailscale devices that are
 	// authorized but have no tags assigned.
-	// Response: {"pending_devices": [{"id": "...", "name": "..."}]}
+	// Query: ?name_prefix=teama- to only list devices whose name has that prefix,
+	// ?state=unauthorized to list devices waiting for authorization instead.
+	// Devices whose owner matches USER_TAG_RULES include "suggested_tags".
+	// ?group_by=user returns the devices grouped by owner instead:
+	// {"groups": [{"user": "a@example.com", "devices": [...]}]}
+	// The response carries an ETag; a matching If-None-Match gets 304 Not Modified.
+	// Response: {"pending_devices": [{"id": "...", "name": "...", "user": "...", "authorized": true, "suggested_tags": [...]}]}
 	mux.HandleFunc("GET /pending-devices", func(w http.ResponseWriter, r *http.Request) {
-		slog.Info("Getting pending devices")
-		pending, err := getPendingDevices(r.Context(), client)
+		slog.InfoContext(r.Context(), "Getting pending devices")
+		filter, err := pendingFilterFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		groupBy := r.URL.Query().Get("group_by")
+		if groupBy != "" && groupBy != "user" {
+			http.Error(w, "group_by must be \"user\"", http.StatusBadRequest)
+			return
+		}
+		filter.Exclude = hidden
+
+		pending, err := getPendingDevices(r.Context(), client, filter)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get pending devices", "error", err)
+			writeError(w, err)
+			return
+		}
+		firstSeen.record(pending)
+		for i := range pending {
+			pending[i].SuggestedTags = suggestTagsForUser(cfg.UserTagRules, pending[i].User)
+		}
+
+		if groupBy == "user" {
+			writeJSONWithETag(w, r, PendingDeviceGroupsResponse{Groups: groupPendingDevicesByUser(pending)})
+			return
+		}
+		writeJSONWithETag(w, r, PendingDevicesResponse{PendingDevices: pending})
+	})
+
+	// GET /pending-devices/count - Returns only the number of pending devices,
+	// for lightweight polling. Accepts the same query as /pending-devices.
+	// Response: {"count": 2}
+	mux.HandleFunc("GET /pending-devices/count", func(w http.ResponseWriter, r *http.Request) {
+		filter, err := pendingFilterFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Exclude = hidden
+
+		pending, err := getPendingDevices(r.Context(), client, filter)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get pending devices", "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PendingDevicesCountResponse{Count: len(pending)})
+	})
+
+	// GET /queue - Returns the pending devices oldest first, with each one's
+	// position and wait since it joined the tailnet, for dashboards. Accepts
+	// the same query as /pending-devices.
+	// Response: {"count": 2, "oldest_wait_seconds": 7200, "average_wait_seconds": 4500,
+	// "queue": [{"position": 1, "device": {...}, "waiting_seconds": 7200}]}
+	mux.HandleFunc("GET /queue", func(w http.ResponseWriter, r *http.Request) {
+		filter, err := pendingFilterFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Exclude = hidden
+
+		pending, err := getPendingDevices(r.Context(), client, filter)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get pending devices", "error", err)
+			writeError(w, err)
+			return
+		}
+		firstSeen.record(pending)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildQueue(pending, time.Now(), firstSeen.seenAt))
+	})
+
+	// GET /devices/missing-tag?tag=tag:managed - Returns authorized devices whose
+	// tags do not include the given tag, including devices that have other tags.
+	// Response: {"devices": [{"id": "...", "name": "...", "authorized": true, "tags": [...]}]}
+	mux.HandleFunc("GET /devices/missing-tag", func(w http.ResponseWriter, r *http.Request) {
+		tag := r.URL.Query().Get("tag")
+		if tag == "" {
+			http.Error(w, "tag query parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		devices, err := getDevicesMissingTag(r.Context(), client, tag)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get devices missing tag", "tag", tag, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(DevicesResponse{Devices: devices})
+	})
+
+	// GET /devices - Returns all devices.
+	// Query: ?name=host1 to only return devices whose name starts with it (case-insensitive).
+	// Response: {"devices": [{"id": "...", "name": "...", "authorized": true, "tags": [...]}]}
+	mux.HandleFunc("GET /devices", func(w http.ResponseWriter, r *http.Request) {
+		devices, err := findDevicesByName(r.Context(), client, r.URL.Query().Get("name"))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list devices", "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(DevicesResponse{Devices: devices})
+	})
+
+	// GET /devices/{deviceID} - Returns live details for a single device.
+	// Response: {"id": "...", "name": "...", "authorized": true, "tags": [...], "os": "linux", "addresses": [...], "last_seen": "..."}
+	// Returns 404 if the device does not exist.
+	mux.HandleFunc("GET /devices/{deviceID}", func(w http.ResponseWriter, r *http.Request) {
+		deviceID := r.PathValue("deviceID")
+
+		device, err := withRetry(r.Context(), func() (Device, error) {
+			return client.Get(r.Context(), deviceID)
+		})
 		if err != nil {
-			slog.Error("Failed to get pending devices", "error", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Failed to get device", "deviceID", deviceID, "error", err)
+			if tsclient.IsNotFound(err) {
+				http.Error(w, "device not found", http.StatusNotFound)
+				return
+			}
+			writeError(w, err)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(PendingDevicesResponse{PendingDevices: pending})
+		json.NewEncoder(w).Encode(device)
 	})
 
-	// GET /tags - Returns available tags from the Tailscale ACL policy.
+	// GET /tags - Returns available tags from the Tailscale ACL policy, omitting FORBIDDEN_TAGS.
+	// Query: ?owner=group:ops to only return tags that owner is listed for in tagOwners.
 	// Response: {"tags": ["tag:a", "tag:b"]}
 	mux.HandleFunc("GET /tags", func(w http.ResponseWriter, r *http.Request) {
-		tags, err := withRetry(r.Context(), func() ([]string, error) {
-			return client.GetAvailableTags(r.Context())
-		})
+		tags, err := getTags(r.Context(), policy, r.URL.Query().Get("owner"))
 		if err != nil {
-			slog.Error("Failed to get available tags", "error", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Failed to get available tags", "error", err)
+			writeError(w, err)
 			return
 		}
+		tags = slices.DeleteFunc(tags, func(tag string) bool {
+			return slices.Contains(cfg.ForbiddenTags, tag)
+		})
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(TagsResponse{Tags: tags})
 	})
 
-	// POST /approve/{deviceID} - Approves a device by applying the specified tags.
-	// Request body: {"tags": ["tag:a", "tag:b"]}
-	// Returns 200 OK on success, 400 on invalid request, 500 on failure.
-	mux.HandleFunc("POST /approve/{deviceID}", func(w http.ResponseWriter, r *http.Request) {
-		deviceID := r.PathValue("deviceID")
+	// GET /acl/tag-owners - Returns the tagOwners map from the Tailscale ACL policy,
+	// showing which users, groups, and tags may own each tag.
+	// Response: {"tag:x": ["group:ops", "tag:y"]}
+	mux.HandleFunc("GET /acl/tag-owners", func(w http.ResponseWriter, r *http.Request) {
+		owners, err := getTagOwners(r.Context(), policy)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get tag owners", "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(owners)
+	})
+
+	locks := &deviceLocks{}
 
+	// POST /approve/{deviceID} - Approves a device by applying the specified tags.
+	// With "authorize": true, an unauthorized device is authorized before tagging.
+	// Approves for the same device are serialized; one that finds the tags already
+	// applied succeeds without changing the device.
+	// Request body: {"tags": ["tag:a", "tag:b"], "authorize": false}
+	// Response: {"dry_run": false}; dry_run is true when dry-run mode left the device untouched.
+	// Returns 200 OK on success, 400 on invalid request, 404 if the device does not exist,
+	// 413 if the body exceeds MAX_REQUEST_BYTES, 500 on failure, 504 on timeout.
+	// With HMAC_SECRET set, a missing, wrong or expired X-Signature gets 401 unless
+	// the request has a verified client certificate.
+	approve := func(w http.ResponseWriter, r *http.Request, deviceID string) {
 		var req ApproveRequest
+		r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxRequestBytes)
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			slog.Error("Failed to decode request body", "error", err)
+			if isBodyTooLarge(err) {
+				writeBodyTooLarge(w, cfg.MaxRequestBytes)
+				return
+			}
+			slog.ErrorContext(r.Context(), "Failed to decode request body", "error", err)
 			http.Error(w, "invalid request body", http.StatusBadRequest)
 			return
 		}
 
-		if len(req.Tags) == 0 {
-			http.Error(w, "at least one tag is required", http.StatusBadRequest)
+		req.Tags = normalizeTags(req.Tags)
+		device, outcome, err := approveDevice(r.Context(), client, policy, cfg, locks, deviceID, req)
+		if err != nil {
+			switch {
+			case isBadRequest(err):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case tsclient.IsNotFound(err):
+				http.Error(w, "device not found", http.StatusNotFound)
+			default:
+				writeError(w, err)
+			}
 			return
 		}
+		switch outcome {
+		case approveDryRun:
+			slog.InfoContext(r.Context(), "Dry run: device would be approved", "deviceID", device.ID, "name", device.Name, "tags", req.Tags)
+		case approveApplied:
+			if elapsed, ok := firstSeen.take(deviceID); ok {
+				timeToApprove.Observe(elapsed.Seconds())
+			}
+			logDeviceApproved(r.Context(), device, req.Tags, "api")
+			event := Event{
+				Type:     eventDeviceApproved,
+				DeviceID: device.ID,
+				Name:     device.Name,
+				Tags:     req.Tags,
+				Source:   "api",
+				Actor:    req.Actor,
+				Time:     time.Now(),
+			}
+			history.add(event)
+			events.Publish(event)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ApproveResponse{DryRun: outcome == approveDryRun})
+	}
 
-		// Validate that all requested tags are in the available tags list
-		availableTags, err := withRetry(r.Context(), func() ([]string, error) {
-			return client.GetAvailableTags(r.Context())
-		})
+	// handleSigned registers an endpoint that changes state. With HMAC_SECRET set
+	// it requires a valid X-Signature or, with TLS_CLIENT_CA_FILE also set, a
+	// client certificate.
+	signedRoutes := make(map[string]bool)
+	handleSigned := func(pattern string, handler http.HandlerFunc) {
+		signedRoutes[pattern] = true
+		mux.Handle(pattern, withHMACSignature(handler, cfg.HMACSecret, cfg.MaxRequestBytes))
+	}
+
+	handleSigned("POST /approve/{deviceID}", func(w http.ResponseWriter, r *http.Request) {
+		approve(w, r, r.PathValue("deviceID"))
+	})
+
+	// POST /approve/by-key/{nodeKey} - Same as /approve/{deviceID}, but resolves the
+	// device by its node key first, so links survive a device being re-registered.
+	// Returns 404 if no device has the given node key.
+	handleSigned("POST /approve/by-key/{nodeKey}", func(w http.ResponseWriter, r *http.Request) {
+		nodeKey := r.PathValue("nodeKey")
+
+		device, found, err := findDeviceByNodeKey(r.Context(), client, nodeKey)
 		if err != nil {
-			slog.Error("Failed to get available tags for validation", "error", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Failed to resolve node key", "nodeKey", nodeKey, "error", err)
+			writeError(w, err)
 			return
 		}
-
-		availableSet := make(map[string]bool)
-		for _, t := range availableTags {
-			availableSet[t] = true
-		}
-		for _, t := range req.Tags {
-			if !availableSet[t] {
-				slog.Error("Invalid tag requested", "tag", t)
-				http.Error(w, "invalid tag: "+t, http.StatusBadRequest)
-				return
-			}
+		if !found {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
 		}
 
-		slog.Info("Approve requested", "deviceID", deviceID, "tags", req.Tags)
+		approve(w, r, device.ID)
+	})
 
-		_, err = withRetry(r.Context(), func() (struct{}, error) {
-			return struct{}{}, client.SetTags(r.Context(), deviceID, req.Tags)
-		})
+	// POST /decline/{deviceID} - Declines a device. The device is hidden from
+	// /pending-devices for DECLINE_SUPPRESS_TTL so it is not re-notified.
+	// Request body (optional): {"reason": "spam"}
+	// Returns 200 OK, 400 for an unknown reason, or 413 if the body exceeds MAX_REQUEST_BYTES.
+	handleSigned("POST /decline/{deviceID}", func(w http.ResponseWriter, r *http.Request) {
+		deviceID := r.PathValue("deviceID")
+		req, err := decodeDeclineRequest(http.MaxBytesReader(w, r.Body, cfg.MaxRequestBytes))
+		if isBodyTooLarge(err) {
+			writeBodyTooLarge(w, cfg.MaxRequestBytes)
+			return
+		}
 		if err != nil {
-			slog.Error("Failed to set tags", "deviceID", deviceID, "error", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		slog.Info("Approved device", "deviceID", deviceID, "tags", req.Tags)
+
+		declined.add(deviceID, cfg.DeclineSuppressTTL)
+		firstSeen.take(deviceID)
+		declinesTotal.WithLabelValues(req.Reason).Inc()
+		logDeviceDeclined(r.Context(), deviceID, req.Reason, cfg.DeclineSuppressTTL)
+		event := Event{
+			Type:     eventDeviceDeclined,
+			DeviceID: deviceID,
+			Reason:   req.Reason,
+			Actor:    req.Actor,
+			Time:     time.Now(),
+		}
+		history.add(event)
+		events.Publish(event)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
 
-	// POST /decline/{deviceID} - Declines a device. Currently only logs the action.
-	// Returns 200 OK.
-	mux.HandleFunc("POST /decline/{deviceID}", func(w http.ResponseWriter, r *http.Request) {
+	// POST /snooze/{deviceID} - Hides a device that is legitimately pending from
+	// /pending-devices for a while, e.g. while waiting on its owner. It reappears
+	// once the snooze ends. Snoozes are kept in memory only.
+	// Request body: {"duration": "4h"} (at most 168h)
+	// Response: {"snoozed_until": "..."}
+	// Returns 400 for an invalid duration, or 413 if the body exceeds MAX_REQUEST_BYTES.
+	handleSigned("POST /snooze/{deviceID}", func(w http.ResponseWriter, r *http.Request) {
 		deviceID := r.PathValue("deviceID")
-		slog.Info("Device declined", "deviceID", deviceID)
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
+		duration, err := decodeSnoozeRequest(http.MaxBytesReader(w, r.Body, cfg.MaxRequestBytes))
+		if isBodyTooLarge(err) {
+			writeBodyTooLarge(w, cfg.MaxRequestBytes)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		snoozed.add(deviceID, duration)
+		slog.InfoContext(r.Context(), "Device snoozed", "deviceID", deviceID, "duration", duration)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SnoozeResponse{SnoozedUntil: time.Now().Add(duration)})
 	})
 
-	server := &http.Server{Addr: ":" + cfg.HTTPPort, Handler: mux}
+	// GET /history - The most recent approve and decline actions, newest first.
+	// Kept in memory for the last 100 actions, so it is empty after a restart.
+	// Query: ?limit=10 (default 10, at most 100).
+	// Response: {"actions": [{"type": "device_approved", "device_id": "...", "tags": [...], "actor": "...", "time": "..."}]}
+	mux.HandleFunc("GET /history", func(w http.ResponseWriter, r *http.Request) {
+		limit := 10
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			parsed, err := strconv.Atoi(limitStr)
+			if err != nil || parsed < 1 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			limit = min(parsed, historySize)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HistoryResponse{Actions: history.recent(limit)})
+	})
+
+	tlsConfig, err := newServerTLSConfig(cfg)
+	if err != nil {
+		slog.Error("Failed to set up TLS", "error", err)
+		os.Exit(1)
+	}
+
+	var handler http.Handler = mux
+	if tlsConfig != nil && cfg.TLSClientCAFile != "" && cfg.HMACSecret != "" {
+		handler = requireClientCert(mux, signedRoutes)
+	}
+	server := &http.Server{Addr: ":" + cfg.HTTPPort, Handler: withRequestID(withTimeout(handler, cfg.HandlerTimeout)), TLSConfig: tlsConfig}
 
 	slog.Info("Starting API server",
 		"tailnet", cfg.Tailnet,
 		"port", cfg.HTTPPort,
+		"tls", tlsConfig != nil,
+		"clientCertRequired", cfg.TLSClientCAFile != "",
 	)
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("HTTP server error", "error", err)
 		}
 	}()
@@ -256,9 +946,55 @@ func main() {
 	<-ctx.Done()
 	slog.Info("Shutting down")
 	server.Shutdown(context.Background())
+	if err := events.Close(); err != nil {
+		slog.Warn("Failed to close event sink", "error", err)
+	}
 }
 
-func getPendingDevices(ctx context.Context, client DevicesClient) ([]PendingDevice, error) {
+// logDeviceApproved emits the device_approved lifecycle event. Any service
+// that tags a device should log it with this exact name and field schema so
+// log queries can follow a device from pending to tagged.
+func logDeviceApproved(ctx context.Context, device Device, tags []string, source string) {
+	slog.InfoContext(ctx, "device_approved",
+		"device_id", device.ID,
+		"name", device.Name,
+		"tags", tags,
+		"source", source,
+	)
+}
+
+func pendingFilterFromRequest(r *http.Request) (PendingFilter, error) {
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "":
+		state = pendingStateUntagged
+	case pendingStateUntagged, pendingStateUnauthorized:
+	default:
+		return PendingFilter{}, errors.New("state must be one of: untagged, unauthorized")
+	}
+
+	return PendingFilter{
+		NamePrefix: r.URL.Query().Get("name_prefix"),
+		State:      state,
+	}, nil
+}
+
+// groupPendingDevicesByUser groups devices by owner, sorted by user. Devices
+// keep their order within a group.
+func groupPendingDevicesByUser(pending []PendingDevice) []PendingDeviceGroup {
+	byUser := make(map[string][]PendingDevice)
+	for _, device := range pending {
+		byUser[device.User] = append(byUser[device.User], device)
+	}
+
+	groups := make([]PendingDeviceGroup, 0, len(byUser))
+	for _, user := range slices.Sorted(maps.Keys(byUser)) {
+		groups = append(groups, PendingDeviceGroup{User: user, Devices: byUser[user]})
+	}
+	return groups
+}
+
+func getPendingDevices(ctx context.Context, client DevicesClient, filter PendingFilter) ([]PendingDevice, error) {
 	devices, err := withRetry(ctx, func() ([]Device, error) {
 		return client.List(ctx)
 	})
@@ -268,50 +1004,221 @@ func getPendingDevices(ctx context.Context, client DevicesClient) ([]PendingDevi
 
 	var pending []PendingDevice
 	for _, device := range devices {
-		if !device.Authorized {
+		if filter.State == pendingStateUnauthorized {
+			if device.Authorized {
+				continue
+			}
+		} else {
+			if !device.Authorized {
+				continue
+			}
+
+			if len(device.Tags) > 0 {
+				continue
+			}
+		}
+
+		if filter.NamePrefix != "" && !strings.HasPrefix(shortName(device.Name), shortName(filter.NamePrefix)) {
 			continue
 		}
 
-		if len(device.Tags) > 0 {
+		if filter.Exclude != nil && filter.Exclude(device.ID) {
 			continue
 		}
 
 		pending = append(pending, PendingDevice{
-			ID:   device.ID,
-			Name: device.Name,
+			ID:         device.ID,
+			Name:       device.Name,
+			ShortName:  shortName(device.Name),
+			Hostname:   device.Hostname,
+			User:       device.User,
+			Authorized: device.Authorized,
+			NodeKey:    device.NodeKey,
+			OS:         device.OS,
+			Created:    device.Created,
 		})
 	}
 
 	return pending, nil
 }
 
-func withRetry[T any](ctx context.Context, fn func() (T, error)) (T, error) {
-	var zero T
-	maxRetries := 5
-	backoff := 1 * time.Second
+// getTags returns the available tags, limited to those owned by owner when it is set.
+func getTags(ctx context.Context, policy PolicyClient, owner string) ([]string, error) {
+	if owner == "" {
+		return withRetry(ctx, func() ([]string, error) {
+			return policy.GetAvailableTags(ctx)
+		})
+	}
 
-	for i := 0; i < maxRetries; i++ {
-		result, err := fn()
-		if err == nil {
-			return result, nil
+	owners, err := withRetry(ctx, func() (map[string][]string, error) {
+		return policy.GetTagOwners(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for tag, tagOwners := range owners {
+		if slices.Contains(tagOwners, owner) {
+			tags = append(tags, tag)
 		}
+	}
+	slices.Sort(tags)
+	return tags, nil
+}
 
-		if i == maxRetries-1 {
-			return zero, err
+// getTagOwners returns the ACL tagOwners map, never nil so it encodes as {}.
+func getTagOwners(ctx context.Context, policy PolicyClient) (map[string][]string, error) {
+	owners, err := withRetry(ctx, func() (map[string][]string, error) {
+		return policy.GetTagOwners(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if owners == nil {
+		owners = map[string][]string{}
+	}
+	return owners, nil
+}
+
+func getDevicesMissingTag(ctx context.Context, client DevicesClient, tag string) ([]Device, error) {
+	devices, err := withRetry(ctx, func() ([]Device, error) {
+		return client.List(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []Device{}
+	for _, device := range devices {
+		if !device.Authorized {
+			continue
 		}
 
-		slog.Warn("Request failed, retrying", "attempt", i+1, "backoff", backoff, "error", err)
+		if slices.Contains(device.Tags, tag) {
+			continue
+		}
 
-		select {
-		case <-ctx.Done():
-			return zero, ctx.Err()
-		case <-time.After(backoff):
-			backoff *= 2
-			if backoff > 30*time.Second {
-				backoff = 30 * time.Second
+		missing = append(missing, device)
+	}
+
+	return missing, nil
+}
+
+// findDevicesByName returns devices whose short name starts with the short
+// form of name, ignoring case. An empty name matches every device.
+func findDevicesByName(ctx context.Context, client DevicesClient, name string) ([]Device, error) {
+	devices, err := withRetry(ctx, func() ([]Device, error) {
+		return client.List(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.ToLower(shortName(name))
+	matched := []Device{}
+	for _, device := range devices {
+		if strings.HasPrefix(strings.ToLower(shortName(device.Name)), name) {
+			matched = append(matched, device)
+		}
+	}
+
+	return matched, nil
+}
+
+// shortName strips the MagicDNS suffix from a device name, so
+// "host.tailnet.ts.net" and "host" both become "host".
+func shortName(name string) string {
+	host, _, _ := strings.Cut(name, ".")
+	return host
+}
+
+// findDeviceByNodeKey looks up the device currently registered with nodeKey.
+func findDeviceByNodeKey(ctx context.Context, client DevicesClient, nodeKey string) (Device, bool, error) {
+	devices, err := withRetry(ctx, func() ([]Device, error) {
+		return client.List(ctx)
+	})
+	if err != nil {
+		return Device{}, false, err
+	}
+
+	for _, device := range devices {
+		if device.NodeKey == nodeKey {
+			return device, true, nil
+		}
+	}
+
+	return Device{}, false, nil
+}
+
+// normalizeTags trims whitespace, drops empty and duplicate tags, and sorts
+// the result, so the tags sent to SetTags and written to logs do not depend
+// on the order a client selected them in.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		result = append(result, t)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// validateTags checks normalized requested tags against the approve policy.
+// The returned error message is suitable for a 400 response.
+func validateTags(tags []string, availableTags []string, cfg Config) error {
+	if cfg.MaxTagsPerDevice > 0 && len(tags) > cfg.MaxTagsPerDevice {
+		return fmt.Errorf("too many tags: %d requested, at most %d allowed", len(tags), cfg.MaxTagsPerDevice)
+	}
+
+	for _, t := range tags {
+		if slices.Contains(cfg.ForbiddenTags, t) {
+			return fmt.Errorf("forbidden_tag: %s may not be applied through this API", t)
+		}
+	}
+
+	if len(cfg.AllowedPrefixes) > 0 {
+		for _, t := range tags {
+			if !slices.ContainsFunc(cfg.AllowedPrefixes, func(prefix string) bool {
+				return strings.HasPrefix(t, prefix)
+			}) {
+				return fmt.Errorf("tag %s is not allowed: must start with one of %s", t, strings.Join(cfg.AllowedPrefixes, ", "))
 			}
 		}
 	}
 
-	return zero, errors.New("max retries exceeded")
+	availableSet := make(map[string]bool)
+	for _, t := range availableTags {
+		availableSet[t] = true
+	}
+	for _, t := range tags {
+		if availableSet[t] {
+			continue
+		}
+		if pattern, ok := matchUnlistedTag(t, cfg.UnlistedTagPatterns); ok {
+			slog.Warn("Allowing tag not listed in ACL", "tag", t, "pattern", pattern)
+			continue
+		}
+		slog.Warn("Rejected tag not listed in ACL", "tag", t)
+		invalidTagRequestsTotal.WithLabelValues(invalidTagLabels.label(t)).Inc()
+		return errors.New("invalid tag: " + t)
+	}
+
+	return nil
+}
+
+// matchUnlistedTag returns the first pattern in patterns that tag matches.
+// Patterns use path.Match syntax, e.g. "tag:client-*".
+func matchUnlistedTag(tag string, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, tag); matched {
+			return pattern, true
+		}
+	}
+	return "", false
 }
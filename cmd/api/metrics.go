@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// retriesTotal counts withRetry calls that needed at least one retry,
+	// labeled by whether they eventually succeeded ("recovered") or gave up ("exhausted").
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tailscale_api_retries_total",
+		Help: "Tailscale API calls that were retried, by outcome.",
+	}, []string{"outcome"})
+
+	// rateLimitedTotal counts Tailscale API attempts rejected with 429 Too Many Requests.
+	rateLimitedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tailscale_api_rate_limited_total",
+		Help: "Tailscale API attempts that were rate limited.",
+	})
+
+	// timeToApprove measures how long a device waited between first appearing
+	// in /pending-devices and being approved.
+	timeToApprove = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tailscale_approval_time_to_approve_seconds",
+		Help:    "Time from a device first appearing as pending to being approved.",
+		Buckets: []float64{60, 300, 900, 1800, 3600, 2 * 3600, 4 * 3600, 8 * 3600, 24 * 3600, 2 * 24 * 3600, 7 * 24 * 3600},
+	})
+
+	// invalidTagRequestsTotal counts approve requests rejected because a tag is
+	// not in the ACL, to spot menus still offering removed or renamed tags.
+	invalidTagRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tailscale_approval_invalid_tag_requests_total",
+		Help: "Approve requests rejected for a tag that does not exist, by tag.",
+	}, []string{"tag"})
+
+	// declinesTotal counts declined devices by the reason category chosen.
+	declinesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tailscale_approval_declines_total",
+		Help: "Devices declined, by reason.",
+	}, []string{"reason"})
+
+	// eventsDroppedTotal counts approval events that were not delivered to
+	// the event sink because its buffer was full or publishing failed.
+	eventsDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tailscale_approval_events_dropped_total",
+		Help: "Approval events dropped instead of being published to the event sink.",
+	})
+
+	// tagsFallbackTotal counts available-tag reads answered from TAGS_FALLBACK
+	// because the live read failed.
+	tagsFallbackTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tailscale_approval_tags_fallback_total",
+		Help: "Available-tag reads that failed and used TAGS_FALLBACK instead.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(retriesTotal, rateLimitedTotal, timeToApprove, invalidTagRequestsTotal, declinesTotal, eventsDroppedTotal, tagsFallbackTotal)
+	for _, reason := range declineReasons {
+		declinesTotal.WithLabelValues(reason)
+	}
+}
+
+// maxInvalidTagLabels caps the distinct tag label values on
+// invalidTagRequestsTotal, since the tag comes from the request.
+const maxInvalidTagLabels = 50
+
+// invalidTagOverflowLabel is used for tags seen after the cap is reached.
+const invalidTagOverflowLabel = "other"
+
+var invalidTagLabels = &labelLimiter{max: maxInvalidTagLabels, seen: make(map[string]bool)}
+
+// labelLimiter hands out label values as-is until max distinct values have
+// been seen, then maps every new value to invalidTagOverflowLabel.
+type labelLimiter struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]bool
+}
+
+func (l *labelLimiter) label(value string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.seen[value] {
+		return value
+	}
+	if len(l.seen) >= l.max {
+		return invalidTagOverflowLabel
+	}
+	l.seen[value] = true
+	return value
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestLabelLimiter_CapsDistinctValues(t *testing.T) {
+	limiter := &labelLimiter{max: 2, seen: make(map[string]bool)}
+
+	if got := limiter.label("tag:a"); got != "tag:a" {
+		t.Errorf("expected tag:a, got %q", got)
+	}
+	if got := limiter.label("tag:b"); got != "tag:b" {
+		t.Errorf("expected tag:b, got %q", got)
+	}
+	if got := limiter.label("tag:c"); got != invalidTagOverflowLabel {
+		t.Errorf("expected overflow label once the cap is reached, got %q", got)
+	}
+	if got := limiter.label("tag:a"); got != "tag:a" {
+		t.Errorf("expected already-seen tag to keep its label, got %q", got)
+	}
+}
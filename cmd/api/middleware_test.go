@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout_ReturnsGatewayTimeoutWhenDeadlineExceeded(t *testing.T) {
+	handler := withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		writeError(w, r.Context().Err())
+	}), 10*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", rec.Code)
+	}
+}
+
+func TestWriteError_WrappedDeadlineIsGatewayTimeout(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, fmt.Errorf("list devices: %w", context.DeadlineExceeded))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", rec.Code)
+	}
+}
+
+func TestWriteError_OtherErrorsAreInternalServerError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, errors.New("boom"))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestWriteJSONWithETag_NotModifiedWhenETagMatches(t *testing.T) {
+	body := PendingDevicesResponse{PendingDevices: []PendingDevice{{ID: "1", Name: "laptop"}}}
+
+	first := httptest.NewRecorder()
+	writeJSONWithETag(first, httptest.NewRequest(http.MethodGet, "/pending-devices", nil), body)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", first.Code, etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/pending-devices", nil)
+	req.Header.Set("If-None-Match", etag)
+	second := httptest.NewRecorder()
+	writeJSONWithETag(second, req, body)
+	if second.Code != http.StatusNotModified || second.Body.Len() != 0 {
+		t.Errorf("expected empty 304, got %d with %q", second.Code, second.Body.String())
+	}
+
+	changed := PendingDevicesResponse{PendingDevices: []PendingDevice{{ID: "2", Name: "phone"}}}
+	third := httptest.NewRecorder()
+	writeJSONWithETag(third, req, changed)
+	if third.Code != http.StatusOK || third.Header().Get("ETag") == etag {
+		t.Errorf("expected 200 with a new ETag after a change, got %d %q", third.Code, third.Header().Get("ETag"))
+	}
+}
+
+func TestWithBearerToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{"no token configured", "", "", http.StatusOK},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer nope", http.StatusUnauthorized},
+		{"wrong scheme", "secret", "Basic secret", http.StatusUnauthorized},
+		{"correct token", "secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			withBearerToken(ok, tt.token).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes every endpoint registered in main. openapi_test.go
+// checks that it stays in sync with the routes and request/response structs.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func serveOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type openAPIDocument struct {
+	OpenAPI    string                               `json:"openapi"`
+	Paths      map[string]map[string]map[string]any `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]any `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPISpec(t *testing.T) openAPIDocument {
+	t.Helper()
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("expected an OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+	return doc
+}
+
+// routePattern matches the route registrations in main.go, e.g.
+// mux.HandleFunc("GET /tags", ...) or handleSigned("POST /approve/{deviceID}", ...).
+var routePattern = regexp.MustCompile(`(?:mux\.Handle(?:Func)?|handleSigned)\("([A-Z]+) (/[^"]*)"`)
+
+func TestOpenAPISpec_CoversEveryRoute(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+
+	source, err := os.ReadFile("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := routePattern.FindAllStringSubmatch(string(source), -1)
+	if len(routes) == 0 {
+		t.Fatal("found no routes in main.go")
+	}
+
+	for _, route := range routes {
+		method, path := strings.ToLower(route[1]), route[2]
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("%s %s is not documented in openapi.json", route[1], path)
+		}
+	}
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			registered := slices.ContainsFunc(routes, func(route []string) bool {
+				return route[1] == strings.ToUpper(method) && route[2] == path
+			})
+			if !registered {
+				t.Errorf("openapi.json documents %s %s, which main.go does not register", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISpec_SchemasMatchStructs(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+
+	for name, value := range map[string]any{
+		"Device":          Device{},
+		"PendingDevice":   PendingDevice{},
+		"ApproveRequest":  ApproveRequest{},
+		"ApproveResponse": ApproveResponse{},
+		"DeclineRequest":  DeclineRequest{},
+		"SnoozeRequest":   SnoozeRequest{},
+		"SnoozeResponse":  SnoozeResponse{},
+		"QueueEntry":      QueueEntry{},
+		"QueueResponse":   QueueResponse{},
+		"Event":           Event{},
+		"ReadyzResponse":  ReadyzResponse{},
+	} {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is missing", name)
+			continue
+		}
+		typ := reflect.TypeOf(value)
+		for j := range typ.NumField() {
+			jsonName, _, _ := strings.Cut(typ.Field(j).Tag.Get("json"), ",")
+			if jsonName == "" || jsonName == "-" {
+				continue
+			}
+			if _, ok := schema.Properties[jsonName]; !ok {
+				t.Errorf("schema %s is missing property %q", name, jsonName)
+			}
+		}
+		if got, want := len(schema.Properties), typ.NumField(); got > want {
+			t.Errorf("schema %s has %d properties, but the struct has only %d fields", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// QueueEntry is one pending device in GET /queue, with its 1-based position
+// and how long it has been waiting since it joined the tailnet.
+type QueueEntry struct {
+	Position       int           `json:"position"`
+	Device         PendingDevice `json:"device"`
+	WaitingSeconds int64         `json:"waiting_seconds"`
+}
+
+// QueueResponse is the body of GET /queue.
+type QueueResponse struct {
+	Count              int          `json:"count"`
+	OldestWaitSeconds  int64        `json:"oldest_wait_seconds"`
+	AverageWaitSeconds int64        `json:"average_wait_seconds"`
+	Queue              []QueueEntry `json:"queue"`
+}
+
+// buildQueue orders pending devices oldest first and computes their waits at
+// now. A device without a creation time waits from when seenAt says it first
+// appeared in /pending-devices; one unknown to both is listed last with a
+// zero wait and left out of the oldest and average waits.
+func buildQueue(pending []PendingDevice, now time.Time, seenAt func(deviceID string) (time.Time, bool)) QueueResponse {
+	since := make(map[string]time.Time, len(pending))
+	for _, device := range pending {
+		if t, ok := waitingSince(device, now, seenAt); ok {
+			since[device.ID] = t
+		}
+	}
+
+	pending = slices.Clone(pending)
+	slices.SortStableFunc(pending, func(a, b PendingDevice) int {
+		ta, oka := since[a.ID]
+		tb, okb := since[b.ID]
+		switch {
+		case !oka && !okb:
+			return 0
+		case !oka:
+			return 1
+		case !okb:
+			return -1
+		}
+		return ta.Compare(tb)
+	})
+
+	resp := QueueResponse{Count: len(pending), Queue: make([]QueueEntry, 0, len(pending))}
+	var total, known int64
+	for idx, device := range pending {
+		var wait int64
+		if t, ok := since[device.ID]; ok {
+			wait = int64(now.Sub(t) / time.Second)
+			resp.OldestWaitSeconds = max(resp.OldestWaitSeconds, wait)
+			total += wait
+			known++
+		}
+		resp.Queue = append(resp.Queue, QueueEntry{Position: idx + 1, Device: device, WaitingSeconds: wait})
+	}
+	if known > 0 {
+		resp.AverageWaitSeconds = total / known
+	}
+	return resp
+}
+
+// waitingSince returns when device started waiting: its creation time, or
+// failing that when seenAt first saw it. Times after now are clamped to now.
+func waitingSince(device PendingDevice, now time.Time, seenAt func(deviceID string) (time.Time, bool)) (time.Time, bool) {
+	since := device.Created
+	if since.IsZero() {
+		var ok bool
+		if since, ok = seenAt(device.ID); !ok {
+			return time.Time{}, false
+		}
+	}
+	if since.After(now) {
+		return now, true
+	}
+	return since, true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func neverSeen(string) (time.Time, bool) { return time.Time{}, false }
+
+func TestBuildQueue_OrdersOldestFirstWithStats(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	pending := []PendingDevice{
+		{ID: "new", Created: now.Add(-30 * time.Minute)},
+		{ID: "unknown"},
+		{ID: "old", Created: now.Add(-2 * time.Hour)},
+	}
+
+	got := buildQueue(pending, now, neverSeen)
+
+	if got.Count != 3 || got.OldestWaitSeconds != 7200 || got.AverageWaitSeconds != 4500 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+	wantIDs := []string{"old", "new", "unknown"}
+	for idx, entry := range got.Queue {
+		if entry.Device.ID != wantIDs[idx] || entry.Position != idx+1 {
+			t.Errorf("position %d: expected %s, got %+v", idx+1, wantIDs[idx], entry)
+		}
+	}
+}
+
+func TestBuildQueue_EmptyQueueEncodesAsList(t *testing.T) {
+	got := buildQueue(nil, time.Now(), neverSeen)
+	if got.Queue == nil || got.Count != 0 || got.AverageWaitSeconds != 0 {
+		t.Errorf("unexpected empty queue: %+v", got)
+	}
+}
+
+func TestBuildQueue_FallsBackToFirstSeen(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	pending := []PendingDevice{
+		{ID: "new", Created: now.Add(-30 * time.Minute)},
+		{ID: "no-created"},
+	}
+	seenAt := func(deviceID string) (time.Time, bool) {
+		if deviceID == "no-created" {
+			return now.Add(-time.Hour), true
+		}
+		return time.Time{}, false
+	}
+
+	got := buildQueue(pending, now, seenAt)
+
+	if got.OldestWaitSeconds != 3600 || got.AverageWaitSeconds != 2700 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+	if got.Queue[0].Device.ID != "no-created" || got.Queue[0].WaitingSeconds != 3600 {
+		t.Errorf("expected the first-seen device first with a 3600s wait, got %+v", got.Queue[0])
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// deviceWriter is the part of DevicesClient that modifies devices.
+type deviceWriter interface {
+	SetTags(ctx context.Context, deviceID string, tags []string) error
+	Authorize(ctx context.Context, deviceID string) error
+	SetKeyExpiry(ctx context.Context, deviceID string, disabled bool) error
+}
+
+// splitKeyClient reads devices and the ACL with one Tailscale client (the
+// TAILSCALE_API_KEY_READONLY key) and sends every write to another.
+type splitKeyClient struct {
+	*tailscaleClient
+	writer deviceWriter
+}
+
+func (c *splitKeyClient) SetTags(ctx context.Context, deviceID string, tags []string) error {
+	return c.writer.SetTags(ctx, deviceID, tags)
+}
+
+func (c *splitKeyClient) Authorize(ctx context.Context, deviceID string) error {
+	return c.writer.Authorize(ctx, deviceID)
+}
+
+func (c *splitKeyClient) SetKeyExpiry(ctx context.Context, deviceID string, disabled bool) error {
+	return c.writer.SetKeyExpiry(ctx, deviceID, disabled)
+}
+
+// dryRunWriter logs each write instead of performing it. It is used when
+// only a read-only key is configured.
+type dryRunWriter struct{}
+
+func (dryRunWriter) SetTags(ctx context.Context, deviceID string, tags []string) error {
+	slog.InfoContext(ctx, "Dry run: not setting tags", "deviceID", deviceID, "tags", tags)
+	return nil
+}
+
+func (dryRunWriter) Authorize(ctx context.Context, deviceID string) error {
+	slog.InfoContext(ctx, "Dry run: not authorizing device", "deviceID", deviceID)
+	return nil
+}
+
+func (dryRunWriter) SetKeyExpiry(ctx context.Context, deviceID string, disabled bool) error {
+	slog.InfoContext(ctx, "Dry run: not setting key expiry", "deviceID", deviceID, "keyExpiryDisabled", disabled)
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithRequestID_EchoesOrGeneratesID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(&requestIDHandler{Handler: slog.NewJSONHandler(&buf, nil)})
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "handled")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "abc123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(requestIDHeader); got != "abc123" {
+		t.Errorf("expected supplied ID to be echoed, got %q", got)
+	}
+	if !strings.Contains(buf.String(), `"request_id":"abc123"`) {
+		t.Errorf("expected log line to carry the request ID, got %s", buf.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get(requestIDHeader); len(got) != 16 {
+		t.Errorf("expected a generated 16 character ID, got %q", got)
+	}
+}
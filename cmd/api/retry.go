@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tsclient "github.com/tailscale/tailscale-client-go/v2"
+)
+
+// Classifier decides whether a failed attempt should be retried and whether
+// the failure was caused by rate limiting.
+type Classifier func(err error) (retry bool, rateLimited bool)
+
+// defaultClassifier retries rate limiting (429), server errors (5xx) and
+// transient network errors. Other API errors such as 403 or 404 will not
+// succeed on retry and are returned immediately.
+func defaultClassifier(err error) (retry bool, rateLimited bool) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, false
+	}
+
+	if status := apiStatusCode(err); status != 0 {
+		return classifyStatus(status)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true, false
+	}
+
+	return false, false
+}
+
+// classifyStatus classifies a Tailscale API response status.
+func classifyStatus(status int) (retry bool, rateLimited bool) {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return true, true
+	case status >= http.StatusInternalServerError:
+		return true, false
+	default:
+		return false, false
+	}
+}
+
+// apiStatusCode returns the HTTP status of a Tailscale API error, or 0 if err
+// is not an API error. tsclient keeps the status unexported, so it is parsed
+// from the "message (status)" form of APIError.Error.
+func apiStatusCode(err error) int {
+	var apiErr tsclient.APIError
+	if !errors.As(err, &apiErr) {
+		return 0
+	}
+	msg := apiErr.Error()
+	open := strings.LastIndex(msg, "(")
+	if open < 0 || !strings.HasSuffix(msg, ")") {
+		return 0
+	}
+	status, err := strconv.Atoi(msg[open+1 : len(msg)-1])
+	if err != nil {
+		return 0
+	}
+	return status
+}
+
+// withRetry calls fn until it succeeds, retrying errors accepted by defaultClassifier.
+func withRetry[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	return withRetryClassifier(ctx, defaultClassifier, fn)
+}
+
+const (
+	// retryAttempts is the total number of calls withRetry makes, including the first.
+	retryAttempts = 5
+	// retryInitialBackoff is the wait before the second attempt; it doubles
+	// after each retry up to retryMaxBackoff.
+	retryInitialBackoff = 1 * time.Second
+	retryMaxBackoff     = 30 * time.Second
+)
+
+// retryAfter waits between attempts. Tests replace it to avoid sleeping.
+var retryAfter = time.After
+
+// withRetryClassifier calls fn until it succeeds, classify reports a
+// non-retryable error, or the attempts are exhausted. It makes at most
+// retryAttempts calls and waits between consecutive calls only, so it never
+// sleeps after the last attempt.
+func withRetryClassifier[T any](ctx context.Context, classify Classifier, fn func() (T, error)) (T, error) {
+	var zero T
+	backoff := retryInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil {
+			if attempt > 1 {
+				retriesTotal.WithLabelValues("recovered").Inc()
+			}
+			tailscaleStatus.record(nil, time.Now())
+			return result, nil
+		}
+
+		retry, rateLimited := classify(err)
+		if rateLimited {
+			rateLimitedTotal.Inc()
+		}
+
+		if !retry || attempt == retryAttempts {
+			if attempt > 1 {
+				retriesTotal.WithLabelValues("exhausted").Inc()
+			}
+			tailscaleStatus.record(err, time.Now())
+			return zero, err
+		}
+
+		slog.WarnContext(ctx, "Request failed, retrying", "attempt", attempt, "backoff", backoff, "rateLimited", rateLimited, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-retryAfter(backoff):
+		}
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		status          int
+		wantRetry       bool
+		wantRateLimited bool
+	}{
+		{429, true, true},
+		{500, true, false},
+		{503, true, false},
+		{400, false, false},
+		{403, false, false},
+		{404, false, false},
+	}
+
+	for _, tt := range tests {
+		retry, rateLimited := classifyStatus(tt.status)
+		if retry != tt.wantRetry || rateLimited != tt.wantRateLimited {
+			t.Errorf("classifyStatus(%d) = (%v, %v), want (%v, %v)", tt.status, retry, rateLimited, tt.wantRetry, tt.wantRateLimited)
+		}
+	}
+}
+
+func TestDefaultClassifier_RetriesNetworkErrors(t *testing.T) {
+	err := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+	if retry, _ := defaultClassifier(err); !retry {
+		t.Error("expected network error to be retried")
+	}
+}
+
+func TestDefaultClassifier_DoesNotRetryContextErrors(t *testing.T) {
+	if retry, _ := defaultClassifier(context.Canceled); retry {
+		t.Error("expected context.Canceled not to be retried")
+	}
+}
+
+func TestWithRetryClassifier_StopsOnNonRetryableError(t *testing.T) {
+	attempts := 0
+	permanent := errors.New("permanent")
+
+	_, err := withRetryClassifier(context.Background(), func(error) (bool, bool) {
+		return false, false
+	}, func() (struct{}, error) {
+		attempts++
+		return struct{}{}, permanent
+	})
+
+	if !errors.Is(err, permanent) {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+// fakeRetryClock replaces retryAfter for the test, recording each wait
+// without sleeping.
+func fakeRetryClock(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	original := retryAfter
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+	t.Cleanup(func() { retryAfter = original })
+	return &waits
+}
+
+func TestWithRetryClassifier_MakesExactlyMaxAttempts(t *testing.T) {
+	waits := fakeRetryClock(t)
+	attempts := 0
+	transient := errors.New("transient")
+
+	_, err := withRetryClassifier(context.Background(), func(error) (bool, bool) {
+		return true, false
+	}, func() (struct{}, error) {
+		attempts++
+		return struct{}{}, transient
+	})
+
+	if !errors.Is(err, transient) {
+		t.Fatalf("expected transient error, got %v", err)
+	}
+	if attempts != retryAttempts {
+		t.Errorf("expected %d attempts, got %d", retryAttempts, attempts)
+	}
+	want := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	if !slices.Equal(*waits, want) {
+		t.Errorf("expected waits %v (none after the last attempt), got %v", want, *waits)
+	}
+}
+
+func TestWithRetryClassifier_NoWaitAfterSuccess(t *testing.T) {
+	waits := fakeRetryClock(t)
+	attempts := 0
+
+	_, err := withRetryClassifier(context.Background(), func(error) (bool, bool) {
+		return true, false
+	}, func() (struct{}, error) {
+		attempts++
+		if attempts < 3 {
+			return struct{}{}, errors.New("transient")
+		}
+		return struct{}{}, nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 3 || len(*waits) != 2 {
+		t.Errorf("expected 3 attempts and 2 waits, got %d attempts and waits %v", attempts, *waits)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(method, path, timestamp, body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(signaturePayload(method, path, timestamp, []byte(body)))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWithHMACSignature(t *testing.T) {
+	const body = `{"tags":["tag:a"]}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-signatureMaxAge-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		want      int
+	}{
+		{"no secret configured", "", "", "", http.StatusOK},
+		{"missing signature", "secret", now, "", http.StatusUnauthorized},
+		{"missing timestamp", "secret", "", sign(http.MethodPost, "/approve/1", "", body, "secret"), http.StatusUnauthorized},
+		{"signed with another secret", "secret", now, sign(http.MethodPost, "/approve/1", now, body, "other"), http.StatusUnauthorized},
+		{"malformed signature", "secret", now, "sha256=zz", http.StatusUnauthorized},
+		{"signed for another device", "secret", now, sign(http.MethodPost, "/approve/2", now, body, "secret"), http.StatusUnauthorized},
+		{"signed for another endpoint", "secret", now, sign(http.MethodPost, "/decline/1", now, body, "secret"), http.StatusUnauthorized},
+		{"stale timestamp", "secret", stale, sign(http.MethodPost, "/approve/1", stale, body, "secret"), http.StatusUnauthorized},
+		{"valid signature", "secret", now, sign(http.MethodPost, "/approve/1", now, body, "secret"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBody string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				gotBody = string(b)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/approve/1", strings.NewReader(body))
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+			if tt.timestamp != "" {
+				req.Header.Set(signatureTimestampHeader, tt.timestamp)
+			}
+			rec := httptest.NewRecorder()
+			withHMACSignature(next, tt.secret, 1024).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, rec.Code)
+			}
+			if tt.want == http.StatusOK && gotBody != body {
+				t.Errorf("expected handler to read the original body, got %q", gotBody)
+			}
+		})
+	}
+}
+
+func TestWithHMACSignature_BodyTooLarge(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/approve/1", strings.NewReader(body))
+	req.Header.Set(signatureHeader, sign(http.MethodPost, "/approve/1", now, body, "secret"))
+	req.Header.Set(signatureTimestampHeader, now)
+	rec := httptest.NewRecorder()
+	withHMACSignature(http.NotFoundHandler(), "secret", 10).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rec.Code)
+	}
+}
+
+func TestWithHMACSignature_ClientCertificateReplacesSignature(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/approve/1", strings.NewReader(`{"tags":["tag:a"]}`))
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	rec := httptest.NewRecorder()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	withHMACSignature(next, "secret", 1024).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected a verified client certificate to be accepted without a signature, got %d", rec.Code)
+	}
+}
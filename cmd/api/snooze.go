@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxSnoozeDuration caps a snooze so a typo cannot hide a device for good.
+const maxSnoozeDuration = 7 * 24 * time.Hour
+
+// SnoozeRequest is the body of POST /snooze/{deviceID}.
+type SnoozeRequest struct {
+	// Duration is a Go duration such as "4h", at most maxSnoozeDuration.
+	Duration string `json:"duration"`
+}
+
+type SnoozeResponse struct {
+	SnoozedUntil time.Time `json:"snoozed_until"`
+}
+
+// decodeSnoozeRequest reads a SnoozeRequest from body and returns its
+// duration. A body over the http.MaxBytesReader limit returns the
+// *http.MaxBytesError.
+func decodeSnoozeRequest(body io.Reader) (time.Duration, error) {
+	var req SnoozeRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		if isBodyTooLarge(err) {
+			return 0, err
+		}
+		return 0, errors.New("invalid request body")
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("duration must be a positive duration such as 4h, got %q", req.Duration)
+	}
+	if duration > maxSnoozeDuration {
+		return 0, fmt.Errorf("duration must be at most %s", maxSnoozeDuration)
+	}
+	return duration, nil
+}
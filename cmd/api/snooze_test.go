@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeSnoozeRequest(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{body: `{"duration": "4h"}`, want: 4 * time.Hour},
+		{body: `{"duration": "168h"}`, want: maxSnoozeDuration},
+		{body: `{"duration": "169h"}`, wantErr: true},
+		{body: `{"duration": "-1h"}`, wantErr: true},
+		{body: `{"duration": "soon"}`, wantErr: true},
+		{body: `{}`, wantErr: true},
+		{body: ``, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := decodeSnoozeRequest(strings.NewReader(tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("body %q: expected an error, got %s", tt.body, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("body %q: expected %s, got %s (err %v)", tt.body, tt.want, got, err)
+		}
+	}
+}
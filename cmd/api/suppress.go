@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// expiringSet is an in-memory set of device IDs whose membership expires
+// after a per-entry TTL. It is used to hide recently declined devices from
+// /pending-devices so the bot does not re-notify them on every poll.
+type expiringSet struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+	now   func() time.Time
+}
+
+func newExpiringSet() *expiringSet {
+	return &expiringSet{
+		until: make(map[string]time.Time),
+		now:   time.Now,
+	}
+}
+
+// add inserts id until ttl from now. A non-positive ttl is a no-op.
+func (s *expiringSet) add(id string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.until[id] = s.now().Add(ttl)
+}
+
+// contains reports whether id is present and not expired, dropping it once expired.
+func (s *expiringSet) contains(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.until[id]
+	if !ok {
+		return false
+	}
+	if !s.now().Before(until) {
+		delete(s.until, id)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tagsSourceReplace offers only the remote catalog's tags.
+	tagsSourceReplace = "replace"
+	// tagsSourceMerge offers the remote catalog's tags in addition to the ACL's.
+	tagsSourceMerge = "merge"
+)
+
+// maxTagsSourceBytes bounds how much of the remote catalog response is read.
+const maxTagsSourceBytes = 1 << 20
+
+// remoteTagsPolicy is a PolicyClient that takes the available tags from a
+// remote JSON catalog ({"tags": ["tag:a", ...]}) owned by another system.
+// The catalog is cached for ttl; if a refresh fails, the last good catalog is
+// served with a warning. Tag owners always come from the ACL.
+type remoteTagsPolicy struct {
+	acl        PolicyClient
+	url        string
+	mode       string
+	ttl        time.Duration
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu        sync.Mutex
+	tags      []string
+	fetchedAt time.Time
+}
+
+func newRemoteTagsPolicy(acl PolicyClient, url, mode string, ttl time.Duration) *remoteTagsPolicy {
+	return &remoteTagsPolicy{
+		acl:        acl,
+		url:        url,
+		mode:       mode,
+		ttl:        ttl,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+}
+
+func (p *remoteTagsPolicy) GetAvailableTags(ctx context.Context) ([]string, error) {
+	remote, err := p.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.mode != tagsSourceMerge {
+		return remote, nil
+	}
+
+	aclTags, err := p.acl.GetAvailableTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	merged := slices.Concat(aclTags, remote)
+	slices.Sort(merged)
+	return slices.Compact(merged), nil
+}
+
+func (p *remoteTagsPolicy) GetTagOwners(ctx context.Context) (map[string][]string, error) {
+	return p.acl.GetTagOwners(ctx)
+}
+
+// catalog returns the cached remote tags, refreshing them once ttl has passed.
+func (p *remoteTagsPolicy) catalog(ctx context.Context) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tags != nil && p.now().Sub(p.fetchedAt) < p.ttl {
+		return p.tags, nil
+	}
+
+	tags, err := p.fetch(ctx)
+	if err != nil {
+		if p.tags != nil {
+			slog.WarnContext(ctx, "Failed to refresh remote tag catalog, using cached tags", "url", p.url, "error", err)
+			return p.tags, nil
+		}
+		return nil, err
+	}
+
+	p.tags = tags
+	p.fetchedAt = p.now()
+	return tags, nil
+}
+
+func (p *remoteTagsPolicy) fetch(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch tag catalog: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch tag catalog: unexpected status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxTagsSourceBytes)).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode tag catalog: %w", err)
+	}
+	if payload.Tags == nil {
+		return nil, errors.New("decode tag catalog: missing \"tags\" list")
+	}
+	for _, tag := range payload.Tags {
+		if !strings.HasPrefix(tag, "tag:") {
+			return nil, fmt.Errorf("decode tag catalog: %q is not a tag", tag)
+		}
+	}
+
+	tags := slices.Clone(payload.Tags)
+	slices.Sort(tags)
+	return slices.Compact(tags), nil
+}
+
+// fallbackTagsPolicy is a PolicyClient that serves a static tag list when the
+// available tags cannot be read, so reviewers can keep approving with a
+// known-safe set during an ACL outage. Tag owners are never substituted.
+type fallbackTagsPolicy struct {
+	policy PolicyClient
+	tags   []string
+}
+
+func (p *fallbackTagsPolicy) GetAvailableTags(ctx context.Context) ([]string, error) {
+	tags, err := withRetry(ctx, func() ([]string, error) {
+		return p.policy.GetAvailableTags(ctx)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to read available tags, validating against TAGS_FALLBACK instead", "fallbackTags", p.tags, "error", err)
+		tagsFallbackTotal.Inc()
+		return p.tags, nil
+	}
+	return tags, nil
+}
+
+func (p *fallbackTagsPolicy) GetTagOwners(ctx context.Context) (map[string][]string, error) {
+	return p.policy.GetTagOwners(ctx)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTagsSourceServer(t *testing.T, body *atomic.Value, status *atomic.Int32) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := status.Load(); code != 0 {
+			w.WriteHeader(int(code))
+			return
+		}
+		w.Write([]byte(body.Load().(string)))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestRemoteTagsPolicy_ReplacesACLTags(t *testing.T) {
+	var body atomic.Value
+	var status atomic.Int32
+	body.Store(`{"tags": ["tag:web", "tag:db"]}`)
+	acl := &mockPolicyClient{tagOwners: map[string][]string{"tag:acl": {"group:ops"}}}
+	policy := newRemoteTagsPolicy(acl, newTagsSourceServer(t, &body, &status), tagsSourceReplace, time.Minute)
+
+	tags, err := policy.GetAvailableTags(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(tags, []string{"tag:db", "tag:web"}) {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
+
+func TestRemoteTagsPolicy_MergesWithACLTags(t *testing.T) {
+	var body atomic.Value
+	var status atomic.Int32
+	body.Store(`{"tags": ["tag:web", "tag:acl"]}`)
+	acl := &mockPolicyClient{tagOwners: map[string][]string{"tag:acl": {"group:ops"}}}
+	policy := newRemoteTagsPolicy(acl, newTagsSourceServer(t, &body, &status), tagsSourceMerge, time.Minute)
+
+	tags, err := policy.GetAvailableTags(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(tags, []string{"tag:acl", "tag:web"}) {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
+
+func TestRemoteTagsPolicy_RejectsBadPayload(t *testing.T) {
+	for _, payload := range []string{`not json`, `{"items": []}`, `{"tags": ["web"]}`} {
+		var body atomic.Value
+		var status atomic.Int32
+		body.Store(payload)
+		policy := newRemoteTagsPolicy(&mockPolicyClient{}, newTagsSourceServer(t, &body, &status), tagsSourceReplace, time.Minute)
+
+		if _, err := policy.GetAvailableTags(context.Background()); err == nil {
+			t.Errorf("payload %q: expected error, got nil", payload)
+		}
+	}
+}
+
+func TestRemoteTagsPolicy_ServesCachedTagsWhenRefreshFails(t *testing.T) {
+	var body atomic.Value
+	var status atomic.Int32
+	body.Store(`{"tags": ["tag:web"]}`)
+	policy := newRemoteTagsPolicy(&mockPolicyClient{}, newTagsSourceServer(t, &body, &status), tagsSourceReplace, time.Minute)
+	now := time.Now()
+	policy.now = func() time.Time { return now }
+
+	if _, err := policy.GetAvailableTags(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Within the TTL the catalog is not fetched again.
+	body.Store(`{"tags": ["tag:new"]}`)
+	tags, _ := policy.GetAvailableTags(context.Background())
+	if !slices.Equal(tags, []string{"tag:web"}) {
+		t.Errorf("expected cached tags within TTL, got %v", tags)
+	}
+
+	// After the TTL a failed refresh falls back to the cached catalog.
+	now = now.Add(time.Minute)
+	status.Store(http.StatusInternalServerError)
+	tags, err := policy.GetAvailableTags(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(tags, []string{"tag:web"}) {
+		t.Errorf("expected cached tags after failed refresh, got %v", tags)
+	}
+
+	// A successful refresh replaces the catalog.
+	status.Store(0)
+	tags, _ = policy.GetAvailableTags(context.Background())
+	if !slices.Equal(tags, []string{"tag:new"}) {
+		t.Errorf("expected refreshed tags, got %v", tags)
+	}
+}
+
+func TestFallbackTagsPolicy_UsesFallbackOnlyWhenReadFails(t *testing.T) {
+	acl := &mockPolicyClient{tagOwners: map[string][]string{"tag:acl": {"group:ops"}}}
+	policy := &fallbackTagsPolicy{policy: acl, tags: []string{"tag:safe"}}
+
+	tags, err := policy.GetAvailableTags(context.Background())
+	if err != nil || !slices.Equal(tags, []string{"tag:acl"}) {
+		t.Fatalf("expected live tags, got %v (err %v)", tags, err)
+	}
+
+	acl.err = errors.New("acl unavailable")
+	tags, err = policy.GetAvailableTags(context.Background())
+	if err != nil || !slices.Equal(tags, []string{"tag:safe"}) {
+		t.Fatalf("expected fallback tags, got %v (err %v)", tags, err)
+	}
+	if _, err := policy.GetTagOwners(context.Background()); err == nil {
+		t.Error("expected tag owners to still fail without the ACL")
+	}
+}
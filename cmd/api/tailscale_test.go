@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tsclient "github.com/tailscale/tailscale-client-go/v2"
+)
+
+// fakeTailscale is an httptest server mimicking the subset of the Tailscale
+// API used by tailscaleClient, so the tsclient wire format is exercised.
+type fakeTailscale struct {
+	mu        sync.Mutex
+	devices   []map[string]any
+	tagOwners map[string][]string
+	setTags   map[string][]string
+	// status, when non-zero, is returned with an API error for every request.
+	status int
+	// setTagsStatus, when non-zero, is returned with an API error for SetTags.
+	setTagsStatus int
+}
+
+func newFakeTailscale(t *testing.T, fake *fakeTailscale) *tailscaleClient {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v2/tailnet/example.com/devices", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"devices": fake.devices})
+	})
+	mux.HandleFunc("GET /api/v2/device/{deviceID}", func(w http.ResponseWriter, r *http.Request) {
+		for _, d := range fake.devices {
+			if d["id"] == r.PathValue("deviceID") {
+				json.NewEncoder(w).Encode(d)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "not found"})
+	})
+	mux.HandleFunc("POST /api/v2/device/{deviceID}/tags", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if fake.setTagsStatus != 0 {
+			w.WriteHeader(fake.setTagsStatus)
+			json.NewEncoder(w).Encode(map[string]string{"message": http.StatusText(fake.setTagsStatus)})
+			return
+		}
+		fake.mu.Lock()
+		fake.setTags[r.PathValue("deviceID")] = body.Tags
+		fake.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /api/v2/tailnet/example.com/acl", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"tagOwners": fake.tagOwners})
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fake.status != 0 {
+			w.WriteHeader(fake.status)
+			json.NewEncoder(w).Encode(map[string]string{"message": http.StatusText(fake.status)})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	baseURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	if fake.setTags == nil {
+		fake.setTags = make(map[string][]string)
+	}
+
+	return &tailscaleClient{
+		client: &tsclient.Client{
+			BaseURL: baseURL,
+			Tailnet: "example.com",
+			APIKey:  "tskey-test",
+		},
+	}
+}
+
+func TestTailscaleClient_List(t *testing.T) {
+	client := newFakeTailscale(t, &fakeTailscale{
+		devices: []map[string]any{
+			{"id": "1", "name": "host1.example.ts.net", "authorized": true, "tags": []string{"tag:web"}},
+			{"id": "2", "name": "host2.example.ts.net", "authorized": false},
+		},
+	})
+
+	devices, err := client.List(context.Background())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(devices))
+	}
+	if devices[0].ID != "1" || devices[0].Name != "host1.example.ts.net" || !devices[0].Authorized || !slices.Equal(devices[0].Tags, []string{"tag:web"}) {
+		t.Errorf("unexpected first device: %+v", devices[0])
+	}
+	if devices[1].Authorized {
+		t.Errorf("expected second device to be unauthorized: %+v", devices[1])
+	}
+	if encoded, _ := json.Marshal(devices[1]); !strings.Contains(string(encoded), `"tags":[]`) {
+		t.Errorf("expected a device without tags to encode tags as [], got %s", encoded)
+	}
+}
+
+func TestTailscaleClient_SetTags(t *testing.T) {
+	fake := &fakeTailscale{}
+	client := newFakeTailscale(t, fake)
+
+	if err := client.SetTags(context.Background(), "1", []string{"tag:a", "tag:b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(fake.setTags["1"], []string{"tag:a", "tag:b"}) {
+		t.Errorf("unexpected tags sent: %v", fake.setTags)
+	}
+}
+
+func TestTailscaleClient_GetAvailableTags(t *testing.T) {
+	client := newFakeTailscale(t, &fakeTailscale{
+		tagOwners: map[string][]string{
+			"tag:web": {"group:ops"},
+			"tag:db":  {"group:ops", "tag:web"},
+		},
+	})
+
+	tags, err := client.GetAvailableTags(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(tags, []string{"tag:db", "tag:web"}) {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+
+	owners, err := client.GetTagOwners(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(owners["tag:db"], []string{"group:ops", "tag:web"}) {
+		t.Errorf("unexpected owners: %v", owners)
+	}
+}
+
+func TestTailscaleClient_GetIncludesDetails(t *testing.T) {
+	client := newFakeTailscale(t, &fakeTailscale{
+		devices: []map[string]any{
+			{"id": "1", "name": "host1", "os": "linux", "addresses": []string{"100.64.0.1"}, "lastSeen": "2024-01-02T03:04:05Z"},
+		},
+	})
+
+	device, err := client.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if device.OS != "linux" || !slices.Equal(device.Addresses, []string{"100.64.0.1"}) {
+		t.Errorf("unexpected device: %+v", device)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !device.LastSeen.Equal(want) {
+		t.Errorf("expected last seen %v, got %v", want, device.LastSeen)
+	}
+}
+
+func TestGetTagOwners_ReturnsPolicyMappingIntact(t *testing.T) {
+	tagOwners := map[string][]string{
+		"tag:web": {"group:ops"},
+		"tag:db":  {"group:ops", "tag:web"},
+		"tag:ci":  {"autogroup:admin", "user@example.com"},
+	}
+	client := newFakeTailscale(t, &fakeTailscale{tagOwners: tagOwners})
+
+	owners, err := getTagOwners(context.Background(), client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(owners) != len(tagOwners) {
+		t.Fatalf("expected %d tags, got %v", len(tagOwners), owners)
+	}
+	for tag, want := range tagOwners {
+		if !slices.Equal(owners[tag], want) {
+			t.Errorf("owners of %s: expected %v, got %v", tag, want, owners[tag])
+		}
+	}
+}
+
+func TestTailscaleClient_GetNotFound(t *testing.T) {
+	client := newFakeTailscale(t, &fakeTailscale{})
+
+	_, err := client.Get(context.Background(), "missing")
+
+	if !tsclient.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestAPIStatusCode_ParsesTailscaleErrors(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusTooManyRequests, http.StatusBadGateway} {
+		client := newFakeTailscale(t, &fakeTailscale{status: status})
+
+		_, err := client.List(context.Background())
+
+		if got := apiStatusCode(err); got != status {
+			t.Errorf("apiStatusCode() = %d, want %d (error: %v)", got, status, err)
+		}
+	}
+}
+
+func TestCheckAPIKeyScopes_WriteScope(t *testing.T) {
+	tests := []struct {
+		name          string
+		setTagsStatus int
+		dryRun        bool
+		wantErr       bool
+	}{
+		{"forbidden", http.StatusForbidden, false, true},
+		{"unauthorized", http.StatusUnauthorized, false, true},
+		{"not found is inconclusive", http.StatusNotFound, false, false},
+		{"bad request is inconclusive", http.StatusBadRequest, false, false},
+		{"dry run skips the write", http.StatusForbidden, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeTailscale{setTagsStatus: tt.setTagsStatus}
+			client := newFakeTailscale(t, fake)
+
+			err := checkAPIKeyScopes(context.Background(), client, client, tt.dryRun)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "devices:write") {
+				t.Errorf("expected the error to name devices:write, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// newServerTLSConfig returns the TLS config for the HTTP server, or nil when
+// TLS is not configured. With TLSClientCAFile set, clients must present a
+// certificate signed by one of its CAs. When HMACSecret is also set, a valid
+// signature is accepted instead of a certificate on the signed routes, so the
+// handshake only verifies a certificate if one is given and
+// requireClientCert enforces it on every other route.
+func newServerTLSConfig(cfg Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSClientCAFile != "" {
+		pool, err := loadCertPool(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE: %w", err)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.HMACSecret != "" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return tlsConfig, nil
+}
+
+// hasClientCert reports whether r came with a client certificate verified
+// against TLS_CLIENT_CA_FILE.
+func hasClientCert(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
+
+// requireClientCert responds 401 to requests without a verified client
+// certificate, except on mux routes in signedRoutes, where withHMACSignature
+// accepts a valid signature instead.
+func requireClientCert(mux *http.ServeMux, signedRoutes map[string]bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); !hasClientCert(r) && !signedRoutes[pattern] {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// loadCertPool reads PEM-encoded CA certificates from path.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no PEM certificates found")
+	}
+	return pool, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// parseUserTagRules parses USER_TAG_RULES, a JSON object mapping an owner
+// pattern to tags, e.g. {"@contractor.example.com": ["tag:contractor"]}.
+// A pattern starting with "@" matches every user in that email domain; any
+// other pattern matches a single user exactly.
+func parseUserTagRules(value string) (map[string][]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var rules map[string][]string
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("must be a JSON object of pattern to tags: %w", err)
+	}
+	for pattern, tags := range rules {
+		if pattern == "" || pattern == "@" {
+			return nil, fmt.Errorf("has an empty pattern")
+		}
+		for _, tag := range tags {
+			if !strings.HasPrefix(tag, "tag:") {
+				return nil, fmt.Errorf("pattern %q has %q, tags must start with \"tag:\"", pattern, tag)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// suggestTagsForUser returns the tags of every rule matching user, sorted and
+// without duplicates. Matching ignores case.
+func suggestTagsForUser(rules map[string][]string, user string) []string {
+	if user == "" {
+		return nil
+	}
+	user = strings.ToLower(user)
+
+	var tags []string
+	for pattern, patternTags := range rules {
+		pattern = strings.ToLower(pattern)
+		if user == pattern || (strings.HasPrefix(pattern, "@") && strings.HasSuffix(user, pattern)) {
+			tags = append(tags, patternTags...)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSuggestTagsForUser_MatchesExactUserAndDomain(t *testing.T) {
+	rules, err := parseUserTagRules(`{"@contractor.example.com": ["tag:contractor"], "alice@example.com": ["tag:dev", "tag:contractor"]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := suggestTagsForUser(rules, "Bob@Contractor.example.com"); !slices.Equal(got, []string{"tag:contractor"}) {
+		t.Errorf("domain rule: unexpected tags %v", got)
+	}
+	if got := suggestTagsForUser(rules, "alice@example.com"); !slices.Equal(got, []string{"tag:contractor", "tag:dev"}) {
+		t.Errorf("exact rule: unexpected tags %v", got)
+	}
+}
+
+func TestSuggestTagsForUser_NoMatch(t *testing.T) {
+	rules, err := parseUserTagRules(`{"@contractor.example.com": ["tag:contractor"], "alice@example.com": ["tag:dev"]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, user := range []string{"carol@example.com", "bob@notcontractor.example.com.evil", ""} {
+		if got := suggestTagsForUser(rules, user); len(got) != 0 {
+			t.Errorf("user %q: expected no tags, got %v", user, got)
+		}
+	}
+}
+
+func TestParseUserTagRules_RejectsInvalidTags(t *testing.T) {
+	if _, err := parseUserTagRules(`{"@example.com": ["contractor"]}`); err == nil {
+		t.Error("expected error for tag without tag: prefix")
+	}
+	if _, err := parseUserTagRules(`not json`); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
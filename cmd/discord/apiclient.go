@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newAPIHTTPClient returns the client used for API requests. With
+// TLS_CLIENT_CERT_FILE set it presents that certificate for mutual TLS.
+func newAPIHTTPClient(cfg Config) (*http.Client, error) {
+	client := &http.Client{Timeout: cfg.APIClientTimeout}
+	if cfg.TLSClientCertFile == "" && cfg.TLSCAFile == "" {
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSClientCertFile, cfg.TLSClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLS_CLIENT_CERT_FILE/TLS_CLIENT_KEY_FILE: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLS_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("TLS_CA_FILE: no PEM certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client.Transport = transport
+	return client, nil
+}
+
+// apiGet sends a GET request for path to the first reachable API target.
+func apiGet(cfg Config, httpClient *http.Client, path string) (*http.Response, error) {
+	return apiDo(cfg, httpClient, http.MethodGet, path, nil, nil)
+}
+
+// apiPost sends a JSON POST request for path to the first reachable API target.
+func apiPost(cfg Config, httpClient *http.Client, path string, body []byte) (*http.Response, error) {
+	return apiDo(cfg, httpClient, http.MethodPost, path, body, nil)
+}
+
+// apiDo tries each of cfg.APIURLs in order. It only moves on to the next
+// target when a target cannot be connected to; any HTTP response, including
+// an error status, is returned to the caller. Other failures, such as a
+// timeout or a TLS error, are returned without failover, since the request
+// may already have been acted on or the next target would fail the same way.
+// header is added to every request and may be nil.
+func apiDo(cfg Config, httpClient *http.Client, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	// One ID per call, kept across failover, so the API's logs for this
+	// request can be found from the bot's.
+	requestID := newRequestID()
+	var errs []error
+	for _, target := range cfg.APIURLs {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, target+path, reader)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("X-Request-ID", requestID)
+		if method == http.MethodPost {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if cfg.HMACSecret != "" {
+			signRequest(req, path, body, cfg.HMACSecret, time.Now())
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			errs = append(errs, err)
+			if !isAPIUnreachable(err) {
+				slog.Error("API request failed", "target", target, "method", method, "path", path, "requestID", requestID, "error", err)
+				break
+			}
+			slog.Warn("API target unreachable", "target", target, "requestID", requestID, "error", err)
+			continue
+		}
+		health.recordAPICall(true)
+		slog.Info("API request served", "target", target, "method", method, "path", path, "status", resp.StatusCode, "requestID", requestID)
+		return resp, nil
+	}
+	health.recordAPICall(false)
+	return nil, errors.Join(errs...)
+}
+
+// apiUnreachableMessage is shown to users instead of raw dial errors.
+const apiUnreachableMessage = "The approval backend is currently unreachable. Please try again shortly."
+
+// apiOutcomeUnknownMessage is shown when the API did not answer in time. The
+// request may have been applied, so users are told to check before retrying.
+const apiOutcomeUnknownMessage = "the approval backend did not respond in time, so the outcome is unknown. Check the device before retrying."
+
+// isAPIUnreachable reports whether err means no connection to the API could
+// be made (refused, no route, DNS failure), so the request was never sent.
+func isAPIUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isAPITimeout reports whether err is a request that timed out, possibly
+// after the API received it.
+func isAPITimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// apiErrorMessage formats err from an API call for a Discord message,
+// replacing connection errors with apiUnreachableMessage and timeouts with
+// apiOutcomeUnknownMessage. The raw error is logged by the caller.
+func apiErrorMessage(action string, err error) string {
+	switch {
+	case isAPIUnreachable(err):
+		return apiUnreachableMessage
+	case isAPITimeout(err):
+		return fmt.Sprintf("%s: %s", action, apiOutcomeUnknownMessage)
+	}
+	return fmt.Sprintf("%s: %s", action, err.Error())
+}
+
+// signRequest sets X-Signature-Timestamp and X-Signature on req, matching the
+// API's HMAC_SECRET check: "sha256=" and the hex HMAC-SHA256 of the method,
+// the API path (without query), the timestamp and the body, joined by
+// newlines. path is signed as passed to apiDo, since a target URL's own path
+// prefix is usually stripped by a proxy before the API sees it.
+func signRequest(req *http.Request, path string, body []byte, secret string, now time.Time) {
+	path, _, _ = strings.Cut(path, "?")
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(req.Method + "\n" + path + "\n" + timestamp + "\n"))
+	mac.Write(body)
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
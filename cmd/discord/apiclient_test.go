@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignRequest_BindsMethodPathAndTimestamp(t *testing.T) {
+	body := []byte(`{"tags":["tag:a"]}`)
+	req, _ := http.NewRequest(http.MethodPost, "http://api:8080/approve/1", nil)
+
+	signRequest(req, "/approve/1?dry=1", body, "secret", time.Unix(1700000000, 0))
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("POST\n/approve/1\n1700000000\n" + string(body)))
+	if got := req.Header.Get("X-Signature-Timestamp"); got != "1700000000" {
+		t.Errorf("unexpected timestamp %q", got)
+	}
+	if got, want := req.Header.Get("X-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("expected signature %s, got %s", want, got)
+	}
+}
+
+func TestAPIErrorMessage_ClassifiesTransportErrors(t *testing.T) {
+	dial := &url.Error{Op: "Post", URL: "http://api:8080/approve/1", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+	timeout := &url.Error{Op: "Post", URL: "http://api:8080/approve/1", Err: context.DeadlineExceeded}
+	tlsErr := &url.Error{Op: "Post", URL: "https://api:8080/approve/1", Err: x509.UnknownAuthorityError{}}
+
+	if got := apiErrorMessage("Failed to approve device", dial); got != apiUnreachableMessage {
+		t.Errorf("dial error: got %q", got)
+	}
+	if got := apiErrorMessage("Failed to approve device", timeout); !strings.Contains(got, "outcome is unknown") {
+		t.Errorf("timeout: got %q", got)
+	}
+	if got := apiErrorMessage("Failed to approve device", tlsErr); got == apiUnreachableMessage || strings.Contains(got, "outcome is unknown") {
+		t.Errorf("TLS error: got %q", got)
+	}
+}
+
+func TestAPIDo_FailsOverOnlyWhenUnreachable(t *testing.T) {
+	var hits atomic.Int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer healthy.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+
+	httpClient := &http.Client{Timeout: 50 * time.Millisecond}
+
+	resp, err := apiDo(Config{APIURLs: []string{closedURL, healthy.URL}}, httpClient, http.MethodGet, "/pending-devices", nil, nil)
+	if err != nil {
+		t.Fatalf("expected failover past the closed target, got %v", err)
+	}
+	resp.Body.Close()
+
+	_, err = apiDo(Config{APIURLs: []string{slow.URL, healthy.URL}}, httpClient, http.MethodPost, "/approve/1", []byte(`{}`), nil)
+	if err == nil || !isAPITimeout(err) {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected no failover after a timeout, healthy target got %d requests", hits.Load())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// autoApproveActor is recorded as the approver of auto-approved devices.
+const autoApproveActor = "auto-approve"
+
+// autoApproveRule approves devices matching every non-empty criterion with
+// Tags, without review. User and Name are path.Match globs, e.g.
+// "admin@example.com" or "ci-*"; OS is compared case-insensitively.
+type autoApproveRule struct {
+	User string   `json:"user"`
+	Name string   `json:"name"`
+	OS   string   `json:"os"`
+	Tags []string `json:"tags"`
+}
+
+// parseAutoApproveRules parses AUTO_APPROVE_RULES, a JSON array of rules such
+// as [{"user": "admin@example.com", "os": "linux", "tags": ["tag:server"]}].
+// Rules may not apply tags that need dual approval, since nobody reviews them.
+func parseAutoApproveRules(value string, dualApprovalTags []string) ([]autoApproveRule, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var rules []autoApproveRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("must be a JSON array of rules: %w", err)
+	}
+	for idx, rule := range rules {
+		if rule.User == "" && rule.Name == "" && rule.OS == "" {
+			return nil, fmt.Errorf("rule %d must match on at least one of user, name or os", idx)
+		}
+		for _, pattern := range []string{rule.User, rule.Name} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %d has invalid pattern %q: %w", idx, pattern, err)
+			}
+		}
+		if len(rule.Tags) == 0 {
+			return nil, fmt.Errorf("rule %d has no tags", idx)
+		}
+		for _, tag := range rule.Tags {
+			if !strings.HasPrefix(tag, "tag:") {
+				return nil, fmt.Errorf("rule %d has %q, tags must start with \"tag:\"", idx, tag)
+			}
+			if slices.Contains(dualApprovalTags, tag) {
+				return nil, fmt.Errorf("rule %d has %q, which requires dual approval", idx, tag)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// matches reports whether device meets every criterion set on the rule. The
+// name pattern is tried against both the full and the short device name.
+func (r autoApproveRule) matches(device PendingDevice) bool {
+	if r.User != "" {
+		if ok, _ := path.Match(r.User, device.User); !ok {
+			return false
+		}
+	}
+	if r.Name != "" {
+		full, _ := path.Match(r.Name, device.Name)
+		short, _ := path.Match(r.Name, device.ShortName)
+		if !full && !short {
+			return false
+		}
+	}
+	if r.OS != "" && !strings.EqualFold(r.OS, device.OS) {
+		return false
+	}
+	return true
+}
+
+// matchAutoApproveRule returns the first rule matching device.
+func matchAutoApproveRule(rules []autoApproveRule, device PendingDevice) (autoApproveRule, bool) {
+	for _, rule := range rules {
+		if rule.matches(device) {
+			return rule, true
+		}
+	}
+	return autoApproveRule{}, false
+}
+
+// autoApprove approves the devices matching AUTO_APPROVE_RULES and announces
+// each one in the notify channel. It returns the devices that still need
+// manual review, including any whose approval failed.
+// runCheckLocked only calls it below the too-many-pending threshold.
+func autoApprove(s *discordgo.Session, cfg Config, httpClient *http.Client, devices []PendingDevice) []PendingDevice {
+	if len(cfg.AutoApproveRules) == 0 {
+		return devices
+	}
+
+	var manual []PendingDevice
+	for _, device := range devices {
+		rule, ok := matchAutoApproveRule(cfg.AutoApproveRules, device)
+		if !ok {
+			manual = append(manual, device)
+			continue
+		}
+
+		dryRun, err := postApproval(cfg, httpClient, device.ID, rule.Tags, autoApproveActor)
+		if err != nil {
+			slog.Error("Auto-approval failed, falling back to manual review", "deviceID", device.ID, "error", err)
+			manual = append(manual, device)
+			continue
+		}
+		if dryRun {
+			slog.Info("Dry run: device would be auto-approved, falling back to manual review", "deviceID", device.ID, "name", device.Name, "tags", rule.Tags)
+			manual = append(manual, device)
+			continue
+		}
+		slog.Info("Device auto-approved", "deviceID", device.ID, "name", device.Name, "tags", rule.Tags)
+		s.ChannelMessageSend(notifyChannelID(cfg), fmt.Sprintf("Auto-approved `%s` with `%s`.", device.DisplayName(), strings.Join(rule.Tags, "`, `")))
+	}
+	return manual
+}
+
+// postApproval calls the approve API outside of any Discord interaction.
+// dryRun reports that the API's dry-run mode left the device untouched.
+func postApproval(cfg Config, httpClient *http.Client, deviceID string, tags []string, actor string) (dryRun bool, err error) {
+	reqBody, _ := json.Marshal(ApproveRequest{Tags: tags, Authorize: cfg.AuthorizeDevices, Actor: actor})
+	tailnetCfg, id := cfg.forDevice(deviceID)
+	resp, err := apiPost(tailnetCfg, httpClient, "/approve/"+id, reqBody)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("controller returned status %d", resp.StatusCode)
+	}
+	return isDryRunApproval(resp), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatchAutoApproveRule(t *testing.T) {
+	rules := []autoApproveRule{
+		{User: "admin@example.com", OS: "linux", Tags: []string{"tag:server"}},
+		{Name: "ci-*", Tags: []string{"tag:ci"}},
+	}
+
+	tests := []struct {
+		name     string
+		device   PendingDevice
+		wantTags string
+	}{
+		{"user and os match", PendingDevice{User: "admin@example.com", OS: "Linux", Name: "db.tailnet.ts.net"}, "tag:server"},
+		{"os does not match", PendingDevice{User: "admin@example.com", OS: "windows", Name: "laptop.tailnet.ts.net"}, ""},
+		{"other user", PendingDevice{User: "someone@example.com", OS: "linux", Name: "db.tailnet.ts.net"}, ""},
+		{"short name glob", PendingDevice{User: "bot@example.com", Name: "ci-runner-1.tailnet.ts.net", ShortName: "ci-runner-1"}, "tag:ci"},
+		{"name glob does not match", PendingDevice{User: "bot@example.com", Name: "web-1.tailnet.ts.net", ShortName: "web-1"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := matchAutoApproveRule(rules, tt.device)
+			if tt.wantTags == "" {
+				if ok {
+					t.Fatalf("expected no rule to match, got %+v", rule)
+				}
+				return
+			}
+			if !ok || strings.Join(rule.Tags, ",") != tt.wantTags {
+				t.Fatalf("expected tags %s, got %+v (matched %t)", tt.wantTags, rule, ok)
+			}
+		})
+	}
+}
+
+func TestParseAutoApproveRules_RejectsUnsafeRules(t *testing.T) {
+	tests := map[string]string{
+		"no criteria":            `[{"tags": ["tag:a"]}]`,
+		"no tags":                `[{"user": "a@example.com"}]`,
+		"not a tag":              `[{"user": "a@example.com", "tags": ["a"]}]`,
+		"bad pattern":            `[{"name": "[", "tags": ["tag:a"]}]`,
+		"requires dual approval": `[{"user": "a@example.com", "tags": ["tag:prod"]}]`,
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseAutoApproveRules(value, []string{"tag:prod"}); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+
+	rules, err := parseAutoApproveRules(`[{"user": "*@example.com", "tags": ["tag:a"]}]`, []string{"tag:prod"})
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("expected one valid rule, got %v, %v", rules, err)
+	}
+}
+
+func TestPostApproval_DryRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantDryRun bool
+	}{
+		{"applied", `{"dry_run":false}`, false},
+		{"dry run", `{"dry_run":true}`, true},
+		{"plain text from an older API", "ok", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			dryRun, err := postApproval(Config{APIURLs: []string{server.URL}}, server.Client(), "1", []string{"tag:a"}, autoApproveActor)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if dryRun != tt.wantDryRun {
+				t.Errorf("expected dryRun %t, got %t", tt.wantDryRun, dryRun)
+			}
+		})
+	}
+}
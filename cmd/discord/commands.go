@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// registerCommands makes the guild's commands exactly match commands with a
+// single bulk overwrite, so restarts update changed commands and remove stale
+// ones instead of creating duplicates. Each command is logged as created,
+// updated or unchanged.
+func registerCommands(s *discordgo.Session, guildID string, commands []*discordgo.ApplicationCommand) error {
+	appID := s.State.User.ID
+
+	existing, err := s.ApplicationCommands(appID, guildID)
+	if err != nil {
+		return fmt.Errorf("list existing commands: %w", err)
+	}
+	existingByName := make(map[string]*discordgo.ApplicationCommand, len(existing))
+	for _, cmd := range existing {
+		existingByName[cmd.Name] = cmd
+	}
+
+	if _, err := s.ApplicationCommandBulkOverwrite(appID, guildID, commands); err != nil {
+		return fmt.Errorf("overwrite commands: %w", err)
+	}
+
+	for _, cmd := range commands {
+		old, ok := existingByName[cmd.Name]
+		delete(existingByName, cmd.Name)
+		switch {
+		case !ok:
+			slog.Info("Registered slash command", "name", cmd.Name, "guildID", guildID, "result", "created")
+		case commandChanged(old, cmd):
+			slog.Info("Registered slash command", "name", cmd.Name, "guildID", guildID, "result", "updated")
+		default:
+			slog.Info("Registered slash command", "name", cmd.Name, "guildID", guildID, "result", "unchanged")
+		}
+	}
+	for name := range existingByName {
+		slog.Info("Removed stale slash command", "name", name, "guildID", guildID)
+	}
+	return nil
+}
+
+// commandChanged reports whether the registered command differs from the
+// wanted definition in the fields this bot sets.
+func commandChanged(registered, wanted *discordgo.ApplicationCommand) bool {
+	if registered.Description != wanted.Description {
+		return true
+	}
+	registeredOptions, _ := json.Marshal(registered.Options)
+	wantedOptions, _ := json.Marshal(wanted.Options)
+	if string(registeredOptions) != string(wantedOptions) {
+		return true
+	}
+	registeredPerms, _ := json.Marshal(registered.DefaultMemberPermissions)
+	wantedPerms, _ := json.Marshal(wanted.DefaultMemberPermissions)
+	return string(registeredPerms) != string(wantedPerms)
+}
+
+// isApprover reports whether the member may run approver-only commands.
+// With no APPROVER_ROLE_IDS configured, everyone is an approver.
+func isApprover(cfg Config, member *discordgo.Member) bool {
+	if len(cfg.ApproverRoleIDs) == 0 {
+		return true
+	}
+	if member == nil {
+		return false
+	}
+	for _, roleID := range member.Roles {
+		if slices.Contains(cfg.ApproverRoleIDs, roleID) {
+			return true
+		}
+	}
+	return false
+}
+
+// respondNotApprover tells the user ephemerally that the command needs the approver role.
+func respondNotApprover(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	respondEphemeral(s, i, "This command is restricted to approvers.")
+}
+
+func handleRefreshCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
+	if !isApprover(cfg, i.Member) {
+		respondNotApprover(s, i)
+		return
+	}
+
+	slog.Info("Refresh command invoked", "user", i.Member.User.Username)
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	count, err := runScheduledCheck(s, cfg, httpClient, true)
+	if err != nil {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: ptr(apiErrorMessage("Refresh failed", err)),
+		})
+		return
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: ptr(fmt.Sprintf("Refresh complete: %d pending device(s) found.", count)),
+	})
+}
+
+// handleInfoButton replies ephemerally with live details for a device. It is
+// read-only, so it is available to everyone.
+func handleInfoButton(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client, deviceID string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	info, err := fetchDeviceInfo(cfg, httpClient, deviceID)
+	if errors.Is(err, errDeviceNotFound) {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: ptr(fmt.Sprintf("Device `%s` no longer exists. It may have been removed from the tailnet.", deviceID)),
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to fetch device info", "deviceID", deviceID, "error", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: ptr(apiErrorMessage("Failed to fetch device info", err)),
+		})
+		return
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: ptr(formatDeviceInfo(info)),
+	})
+}
+
+func formatDeviceInfo(info DeviceInfo) string {
+	lastSeen := "unknown"
+	if !info.LastSeen.IsZero() {
+		lastSeen = fmt.Sprintf("<t:%d:R>", info.LastSeen.Unix())
+	}
+	addresses := "none"
+	if len(info.Addresses) > 0 {
+		addresses = "`" + strings.Join(info.Addresses, "`, `") + "`"
+	}
+	tags := "none"
+	if len(info.Tags) > 0 {
+		tags = "`" + strings.Join(info.Tags, "`, `") + "`"
+	}
+
+	content := fmt.Sprintf("**Device info**\nName: `%s`\nFull name: `%s`\nID: `%s`\nOS: %s\nLast seen: %s\nAddresses: %s\nAuthorized: %t\nTags: %s",
+		shortName(info.Name), info.Name, info.ID, info.OS, lastSeen, addresses, info.Authorized, tags)
+	if info.Tailnet != "" {
+		content += fmt.Sprintf("\nTailnet: `%s`", info.Tailnet)
+	}
+	return content
+}
+
+// handleDeviceCommand looks up a device by name and replies ephemerally with
+// its status. It is read-only, so it is available to everyone.
+func handleDeviceCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
+	var name, tailnet string
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "name":
+			name = strings.TrimSpace(opt.StringValue())
+		case "tailnet":
+			tailnet = opt.StringValue()
+		}
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	devices, err := fetchDevicesByName(cfg, httpClient, tailnet, name)
+	if err != nil {
+		slog.Error("Failed to look up device", "name", name, "error", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: ptr(apiErrorMessage("Failed to look up device", err)),
+		})
+		return
+	}
+
+	device, candidates := matchDeviceName(devices, name)
+	var content string
+	switch {
+	case device != nil:
+		content = formatDeviceInfo(*device)
+	case len(candidates) == 0:
+		content = fmt.Sprintf("No device found matching `%s`.", name)
+	default:
+		names := make([]string, len(candidates))
+		for j, d := range candidates {
+			names[j] = "`" + shortName(d.Name) + "`"
+		}
+		content = fmt.Sprintf("`%s` matches %d devices, please be more specific: %s", name, len(candidates), strings.Join(names, ", "))
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: ptr(content),
+	})
+}
+
+// matchDeviceName picks the device the user meant by name. A device whose
+// short name equals the short form of name wins; otherwise a single prefix
+// match is used. When there is no unique match, the candidates are returned.
+func matchDeviceName(devices []DeviceInfo, name string) (*DeviceInfo, []DeviceInfo) {
+	for j, d := range devices {
+		if strings.EqualFold(shortName(d.Name), shortName(name)) {
+			return &devices[j], nil
+		}
+	}
+	if len(devices) == 1 {
+		return &devices[0], nil
+	}
+	return nil, devices
+}
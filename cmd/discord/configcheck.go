@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// redactedValue replaces a secret in printed config.
+const redactedValue = "REDACTED"
+
+// configCheckRequested reports whether the binary should only validate its
+// configuration, via the -check flag or CONFIG_CHECK=true.
+func configCheckRequested() bool {
+	check := flag.Bool("check", false, "validate configuration, print it with secrets redacted, and exit")
+	flag.Parse()
+	return *check || os.Getenv("CONFIG_CHECK") == "true"
+}
+
+// redacted returns a copy of the config with secrets masked.
+func (c Config) redacted() Config {
+	if c.BotToken != "" {
+		c.BotToken = redactedValue
+	}
+	if c.WebhookSecret != "" {
+		c.WebhookSecret = redactedValue
+	}
+	if c.HMACSecret != "" {
+		c.HMACSecret = redactedValue
+	}
+	return c
+}
+
+// printConfig writes each config field as "Name: value" with secrets masked.
+func printConfig(w io.Writer, cfg Config) {
+	v := reflect.ValueOf(cfg.redacted())
+	for i := range v.NumField() {
+		value := v.Field(i).Interface()
+		if tmpl, ok := value.(*template.Template); ok && tmpl != nil && tmpl.Tree != nil {
+			value = strconv.Quote(tmpl.Root.String())
+		}
+		if bits, ok := value.(*int64); ok && bits != nil {
+			value = *bits
+		}
+		fmt.Fprintf(w, "%s: %v\n", v.Type().Field(i).Name, value)
+	}
+}
+
+// handleConfigCommand shows approvers the running bot's effective config,
+// the same output as -check, so a deploy can be verified from Discord.
+func handleConfigCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config) {
+	if !isApprover(cfg, i.Member) {
+		respondNotApprover(s, i)
+		return
+	}
+
+	var out strings.Builder
+	printConfig(&out, cfg)
+	if paused, until := polling.status(time.Now()); paused && until.IsZero() {
+		out.WriteString("Paused: until /tailscale-resume\n")
+	} else if paused {
+		fmt.Fprintf(&out, "Paused: until %s\n", until.Format(time.RFC3339))
+	}
+	slog.Info("Config shown", "user", i.Member.User.Username)
+
+	// Leave room for the code fence within Discord's 2000-character limit.
+	respondEphemeral(s, i, "```\n"+truncate(out.String(), 1990)+"\n```")
+}
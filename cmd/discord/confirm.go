@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// confirmationTTL bounds how long a tag selection waits for Confirm.
+const confirmationTTL = 15 * time.Minute
+
+// dualApprovalTTL bounds how long a first approval waits for a second approver.
+const dualApprovalTTL = 24 * time.Hour
+
+type pendingConfirmation struct {
+	DeviceID  string
+	Tags      []string
+	CreatedAt time.Time
+	// FirstApproverID and FirstApproverName are set once the first of two
+	// required approvals has been given.
+	FirstApproverID   string
+	FirstApproverName string
+	// KeyExpiryDisabled also disables the device's key expiry on approval.
+	KeyExpiryDisabled bool
+}
+
+func (p pendingConfirmation) expired() bool {
+	ttl := confirmationTTL
+	if p.FirstApproverID != "" {
+		ttl = dualApprovalTTL
+	}
+	return time.Since(p.CreatedAt) > ttl
+}
+
+// confirmationStore keeps tag selections awaiting confirmation, keyed by the
+// approval message ID. Custom IDs are limited to 100 characters, so the
+// selected tags are kept here instead of being encoded into the button.
+type confirmationStore struct {
+	mu      sync.Mutex
+	entries map[string]pendingConfirmation
+}
+
+var confirmations = &confirmationStore{
+	entries: make(map[string]pendingConfirmation),
+}
+
+func (c *confirmationStore) put(messageID string, confirmation pendingConfirmation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.entries {
+		if entry.expired() {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[messageID] = confirmation
+}
+
+// take removes and returns the confirmation for messageID if it exists,
+// matches deviceID, and has not expired.
+func (c *confirmationStore) take(messageID, deviceID string) (pendingConfirmation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[messageID]
+	if !ok || entry.DeviceID != deviceID {
+		return pendingConfirmation{}, false
+	}
+	delete(c.entries, messageID)
+	if entry.expired() {
+		return pendingConfirmation{}, false
+	}
+	return entry, true
+}
+
+// toggleKeyExpiry flips KeyExpiryDisabled on the confirmation for messageID
+// and returns the updated confirmation, if it exists, matches deviceID, and
+// has not expired.
+func (c *confirmationStore) toggleKeyExpiry(messageID, deviceID string) (pendingConfirmation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[messageID]
+	if !ok || entry.DeviceID != deviceID || entry.expired() {
+		return pendingConfirmation{}, false
+	}
+	entry.KeyExpiryDisabled = !entry.KeyExpiryDisabled
+	c.entries[messageID] = entry
+	return entry, true
+}
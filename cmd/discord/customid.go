@@ -0,0 +1,20 @@
+package main
+
+import "strings"
+
+// encodeCustomID builds a component custom ID from an action and its payload
+// (usually a device ID). action must not contain ':'; the payload may, since
+// decodeCustomID only splits at the first one.
+func encodeCustomID(action, payload string) string {
+	return action + ":" + payload
+}
+
+// decodeCustomID splits a custom ID made by encodeCustomID. ok is false when
+// there is no action.
+func decodeCustomID(customID string) (action, payload string, ok bool) {
+	action, payload, ok = strings.Cut(customID, ":")
+	if !ok || action == "" {
+		return "", "", false
+	}
+	return action, payload, true
+}
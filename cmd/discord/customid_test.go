@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeCustomID_KeepsColonsInPayload(t *testing.T) {
+	action, payload, ok := decodeCustomID(encodeCustomID("approve", "prod/n123:tag:a"))
+	if !ok || action != "approve" || payload != "prod/n123:tag:a" {
+		t.Fatalf("got (%q, %q, %t)", action, payload, ok)
+	}
+}
+
+func TestDecodeCustomID_RejectsMissingAction(t *testing.T) {
+	for _, customID := range []string{"", "approve", ":12345"} {
+		if _, _, ok := decodeCustomID(customID); ok {
+			t.Errorf("expected %q to be rejected", customID)
+		}
+	}
+}
+
+func FuzzCustomIDRoundTrip(f *testing.F) {
+	f.Add("approve", "12345")
+	f.Add("select_tags", "prod/n123")
+	f.Add("cancel", "tag:a:b")
+	f.Add("info", "")
+
+	f.Fuzz(func(t *testing.T, action, payload string) {
+		if action == "" || strings.Contains(action, ":") {
+			t.Skip("action must be non-empty and colon-free")
+		}
+		gotAction, gotPayload, ok := decodeCustomID(encodeCustomID(action, payload))
+		if !ok || gotAction != action || gotPayload != payload {
+			t.Fatalf("round trip of (%q, %q) gave (%q, %q, %t)", action, payload, gotAction, gotPayload, ok)
+		}
+	})
+}
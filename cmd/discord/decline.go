@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// declineReason is a decline category the API accepts on POST /decline.
+type declineReason struct {
+	Value string
+	Label string
+}
+
+var declineReasons = []declineReason{
+	{Value: "spam", Label: "Spam"},
+	{Value: "duplicate", Label: "Duplicate device"},
+	{Value: "unauthorized_user", Label: "Unauthorized user"},
+	{Value: "other", Label: "Other"},
+}
+
+func declineReasonLabel(value string) string {
+	for _, reason := range declineReasons {
+		if reason.Value == value {
+			return reason.Label
+		}
+	}
+	return value
+}
+
+// showDeclineReasonMenu replaces the approval message with a menu of decline
+// reasons. The device is declined once a reason is picked.
+func showDeclineReasonMenu(s *discordgo.Session, i *discordgo.InteractionCreate, deviceID string) {
+	options := make([]discordgo.SelectMenuOption, len(declineReasons))
+	for idx, reason := range declineReasons {
+		options[idx] = discordgo.SelectMenuOption{
+			Label: reason.Label,
+			Value: reason.Value,
+		}
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("**Select a reason for declining**\nDevice ID: `%s`", deviceID),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.SelectMenu{
+							CustomID:    encodeCustomID("select_decline", deviceID),
+							Placeholder: "Select a reason...",
+							Options:     options,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Cancel",
+							Style:    discordgo.SecondaryButton,
+							CustomID: encodeCustomID("cancel", deviceID),
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+// handleDeclineReasonSelect declines the device with the selected reason.
+func handleDeclineReasonSelect(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
+	action, deviceID, ok := decodeCustomID(i.MessageComponentData().CustomID)
+	values := i.MessageComponentData().Values
+	if !ok || action != "select_decline" || len(values) != 1 {
+		return
+	}
+	reason := values[0]
+
+	slog.Info("Decline reason selected", "deviceID", deviceID, "reason", reason, "user", i.Member.User.Username)
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+
+	body, _ := json.Marshal(map[string]string{"reason": reason, "actor": i.Member.User.Username})
+	tailnetCfg, id := cfg.forDevice(deviceID)
+	resp, err := apiPost(tailnetCfg, httpClient, "/decline/"+id, body)
+	if err != nil {
+		slog.Error("Failed to call controller", "error", err)
+		s.ChannelMessageSend(i.ChannelID, apiErrorMessage("Failed to decline device", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Controller returned error", "status", resp.StatusCode)
+		s.ChannelMessageSend(i.ChannelID, fmt.Sprintf("Failed to decline device: %s", resp.Status))
+		return
+	}
+
+	approvals.untrack(i.Message.ID)
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:    ptr(fmt.Sprintf("❌ **Declined** by %s (%s)", i.Member.User.Username, declineReasonLabel(reason))),
+		Components: &[]discordgo.MessageComponent{},
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// escalationLevel is how far an unanswered approval prompt has been escalated.
+type escalationLevel int
+
+const (
+	escalationNone escalationLevel = iota
+	// escalationApprovers re-pings the approvers in the prompt's channel.
+	escalationApprovers
+	// escalationAdmin sends a direct message to ESCALATE_ADMIN_USER_ID.
+	escalationAdmin
+)
+
+// escalationSweepInterval is how often open prompts are checked for escalation.
+const escalationSweepInterval = time.Minute
+
+// escalation is a prompt that has reached a new escalation level.
+type escalation struct {
+	messageID string
+	channelID string
+	device    PendingDevice
+	level     escalationLevel
+	age       time.Duration
+}
+
+// dueEscalations returns the prompts that reached a higher escalation level
+// at now and records that level, so each level is sent once per prompt.
+// A zero threshold disables that level. pendingIDs is only called when some
+// prompt is due; prompts whose device is no longer pending, because it was
+// approved or declined elsewhere, are untracked instead of escalated. If
+// pendingIDs fails nothing is recorded, so the prompts are retried next sweep.
+func (t *approvalTracker) dueEscalations(now time.Time, approversAfter, adminAfter time.Duration, pendingIDs func() (map[string]bool, error)) ([]escalation, error) {
+	levelAt := func(age time.Duration) escalationLevel {
+		switch {
+		case adminAfter > 0 && age >= adminAfter:
+			return escalationAdmin
+		case approversAfter > 0 && age >= approversAfter:
+			return escalationApprovers
+		}
+		return escalationNone
+	}
+
+	t.mu.Lock()
+	anyDue := false
+	for _, approval := range t.messages {
+		if levelAt(now.Sub(approval.postedAt)) > approval.escalation {
+			anyDue = true
+			break
+		}
+	}
+	t.mu.Unlock()
+	if !anyDue {
+		return nil, nil
+	}
+
+	// Fetched without holding the lock, since it calls the API.
+	pending, err := pendingIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var due []escalation
+	for messageID, approval := range t.messages {
+		age := now.Sub(approval.postedAt)
+		level := levelAt(age)
+		if level <= approval.escalation {
+			continue
+		}
+		if !pending[approval.device.ID] {
+			slog.Info("Device no longer pending, not escalating its prompt", "deviceID", approval.device.ID, "messageID", messageID)
+			delete(t.messages, messageID)
+			continue
+		}
+		approval.escalation = level
+		due = append(due, escalation{
+			messageID: messageID,
+			channelID: approval.channelID,
+			device:    approval.device,
+			level:     level,
+			age:       age,
+		})
+	}
+	return due, nil
+}
+
+// runEscalationSweeper periodically escalates approval prompts left
+// unanswered past ESCALATE_AFTER and ESCALATE_ADMIN_AFTER.
+func runEscalationSweeper(s *discordgo.Session, cfg Config, httpClient *http.Client) {
+	ticker := time.NewTicker(escalationSweepInterval)
+	defer ticker.Stop()
+
+	pendingIDs := func() (map[string]bool, error) {
+		pending, _, err := fetchPendingDevices(cfg, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		ids := make(map[string]bool, len(pending))
+		for _, device := range pending {
+			ids[device.ID] = true
+		}
+		return ids, nil
+	}
+
+	for now := range ticker.C {
+		due, err := approvals.dueEscalations(now, cfg.EscalateAfter, cfg.EscalateAdminAfter, pendingIDs)
+		if err != nil {
+			slog.Error("Failed to check pending devices for escalation", "error", err)
+			continue
+		}
+		for _, e := range due {
+			sendEscalation(s, cfg, e)
+		}
+	}
+}
+
+func sendEscalation(s *discordgo.Session, cfg Config, e escalation) {
+	age := e.age.Truncate(time.Minute)
+	slog.Info("Escalating unanswered approval", "deviceID", e.device.ID, "level", int(e.level), "age", age)
+
+	switch e.level {
+	case escalationApprovers:
+		content := fmt.Sprintf("%s⏰ `%s` has been waiting for approval for %s.", approverMentions(cfg), e.device.DisplayName(), age)
+		_, err := s.ChannelMessageSendReply(e.channelID, content, &discordgo.MessageReference{MessageID: e.messageID, ChannelID: e.channelID})
+		if err != nil {
+			slog.Error("Failed to send escalation", "deviceID", e.device.ID, "error", err)
+		}
+
+	case escalationAdmin:
+		dm, err := s.UserChannelCreate(cfg.EscalateAdminUserID)
+		if err != nil {
+			slog.Error("Failed to open DM for escalation", "userID", cfg.EscalateAdminUserID, "error", err)
+			return
+		}
+		content := fmt.Sprintf("⏰ `%s` has been waiting for approval in <#%s> for %s.", e.device.DisplayName(), e.channelID, age)
+		if _, err := s.ChannelMessageSend(dm.ID, content); err != nil {
+			slog.Error("Failed to send escalation DM", "deviceID", e.device.ID, "error", err)
+		}
+	}
+}
+
+// approverMentions pings the approver roles, falling back to MENTION_USER_IDS.
+func approverMentions(cfg Config) string {
+	if len(cfg.ApproverRoleIDs) == 0 {
+		return buildMentionString(cfg.MentionUserIDs)
+	}
+	mentions := make([]string, len(cfg.ApproverRoleIDs))
+	for i, id := range cfg.ApproverRoleIDs {
+		mentions[i] = fmt.Sprintf("<@&%s>", id)
+	}
+	return strings.Join(mentions, " ") + "\n"
+}
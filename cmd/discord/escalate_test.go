@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestTracker(now time.Time, devices ...PendingDevice) *approvalTracker {
+	t := &approvalTracker{messages: make(map[string]*trackedApproval), reposts: make(map[string]int)}
+	for _, device := range devices {
+		t.track("msg-"+device.ID, "channel", device, now)
+	}
+	return t
+}
+
+func TestDueEscalations_EscalatesEachLevelOnce(t *testing.T) {
+	posted := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(posted, PendingDevice{ID: "1"})
+	pending := func() (map[string]bool, error) { return map[string]bool{"1": true}, nil }
+
+	steps := []struct {
+		after time.Duration
+		want  escalationLevel // escalationNone for nothing due
+	}{
+		{time.Hour, escalationNone},
+		{4 * time.Hour, escalationApprovers},
+		{5 * time.Hour, escalationNone},
+		{24 * time.Hour, escalationAdmin},
+		{48 * time.Hour, escalationNone},
+	}
+	for _, step := range steps {
+		due, err := tracker.dueEscalations(posted.Add(step.after), 4*time.Hour, 24*time.Hour, pending)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if step.want == escalationNone {
+			if len(due) != 0 {
+				t.Errorf("after %s: expected nothing due, got %+v", step.after, due)
+			}
+			continue
+		}
+		if len(due) != 1 || due[0].level != step.want || due[0].messageID != "msg-1" {
+			t.Errorf("after %s: expected level %d, got %+v", step.after, step.want, due)
+		}
+	}
+}
+
+func TestDueEscalations_UntracksDevicesNoLongerPending(t *testing.T) {
+	posted := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(posted, PendingDevice{ID: "1"}, PendingDevice{ID: "2"})
+
+	due, err := tracker.dueEscalations(posted.Add(5*time.Hour), 4*time.Hour, 0, func() (map[string]bool, error) {
+		return map[string]bool{"2": true}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(due) != 1 || due[0].device.ID != "2" {
+		t.Errorf("expected only device 2 to be escalated, got %+v", due)
+	}
+	if _, ok := tracker.lookup("msg-1"); ok {
+		t.Error("expected the prompt for approved device 1 to be untracked")
+	}
+}
+
+func TestDueEscalations_RetriesWhenPendingFetchFails(t *testing.T) {
+	posted := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(posted, PendingDevice{ID: "1"})
+	now := posted.Add(5 * time.Hour)
+
+	if _, err := tracker.dueEscalations(now, 4*time.Hour, 0, func() (map[string]bool, error) {
+		return nil, errors.New("api down")
+	}); err == nil {
+		t.Fatal("expected the fetch error")
+	}
+
+	due, err := tracker.dueEscalations(now, 4*time.Hour, 0, func() (map[string]bool, error) {
+		return map[string]bool{"1": true}, nil
+	})
+	if err != nil || len(due) != 1 {
+		t.Errorf("expected the escalation on the next sweep, got %+v (err %v)", due, err)
+	}
+}
+
+func TestDueEscalations_SkipsFetchWhenNothingIsDue(t *testing.T) {
+	posted := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(posted, PendingDevice{ID: "1"})
+
+	_, err := tracker.dueEscalations(posted.Add(time.Hour), 4*time.Hour, 0, func() (map[string]bool, error) {
+		t.Fatal("pending devices should not be fetched")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
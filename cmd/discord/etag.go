@@ -0,0 +1,37 @@
+package main
+
+import (
+	"sync"
+)
+
+// pendingCache remembers the last /pending-devices response per API and
+// query with its ETag, so polls can send If-None-Match and reuse the devices
+// on a 304.
+type pendingCache struct {
+	mu      sync.Mutex
+	entries map[string]pendingCacheEntry
+}
+
+type pendingCacheEntry struct {
+	etag    string
+	devices []PendingDevice
+}
+
+var pendingResponses = &pendingCache{entries: make(map[string]pendingCacheEntry)}
+
+func (c *pendingCache) get(key string) (pendingCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *pendingCache) put(key string, entry pendingCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry.etag == "" {
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = entry
+}
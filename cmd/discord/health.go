@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkHealth remembers when the last check succeeded and whether the last
+// API call reached a target, so /readyz never calls Discord or the API.
+type checkHealth struct {
+	mu             sync.Mutex
+	lastCheck      time.Time
+	apiReachable   bool
+	apiCallsSeen   bool
+	lastCheckError string
+}
+
+var health = &checkHealth{}
+
+func (h *checkHealth) recordCheck(err error, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.lastCheckError = err.Error()
+		return
+	}
+	h.lastCheck = now
+	h.lastCheckError = ""
+}
+
+func (h *checkHealth) recordAPICall(reached bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.apiCallsSeen = true
+	h.apiReachable = reached
+}
+
+// ReadyzResponse is the body of GET /readyz.
+type ReadyzResponse struct {
+	Discord        string    `json:"discord"`
+	API            string    `json:"api"`
+	LastCheck      time.Time `json:"last_check,omitzero"`
+	LastCheckError string    `json:"last_check_error,omitempty"`
+}
+
+func (h *checkHealth) readiness(discordReady bool) (ReadyzResponse, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	resp := ReadyzResponse{
+		Discord:        "ok",
+		API:            "ok",
+		LastCheck:      h.lastCheck,
+		LastCheckError: h.lastCheckError,
+	}
+	if !discordReady {
+		resp.Discord = "fail"
+	}
+	if h.apiCallsSeen && !h.apiReachable {
+		resp.API = "fail"
+	}
+	return resp, discordReady && resp.API == "ok"
+}
+
+func newHealthHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	// GET /readyz - Reports the Discord gateway connection and whether the
+	// last API call reached the API, from cached state.
+	// Returns 200 when both are "ok", otherwise 503.
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := health.readiness(session.ready.Load())
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	return mux
+}
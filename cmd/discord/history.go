@@ -0,0 +1,129 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// recentActionsLimit is how many actions /tailscale-recent shows.
+const recentActionsLimit = 10
+
+// HistoryAction is one approve or decline from the API's GET /history.
+type HistoryAction struct {
+	Type     string    `json:"type"`
+	DeviceID string    `json:"device_id"`
+	Name     string    `json:"name"`
+	Tags     []string  `json:"tags"`
+	Reason   string    `json:"reason"`
+	Actor    string    `json:"actor"`
+	Time     time.Time `json:"time"`
+	Tailnet  string    `json:"-"`
+}
+
+type HistoryResponse struct {
+	Actions []HistoryAction `json:"actions"`
+}
+
+// fetchRecentActions returns the latest limit actions across all tailnets,
+// newest first.
+func fetchRecentActions(cfg Config, httpClient *http.Client, limit int) ([]HistoryAction, error) {
+	var actions []HistoryAction
+	for _, t := range cfg.tailnetTargets() {
+		resp, err := apiGet(cfg.forTailnet(t.Name), httpClient, fmt.Sprintf("/history?limit=%d", limit))
+		if err != nil {
+			return nil, err
+		}
+		var res HistoryResponse
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("controller returned status %d", resp.StatusCode)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&res)
+		}
+		resp.Body.Close()
+		if err != nil {
+			if t.Name != "" {
+				err = fmt.Errorf("tailnet %s: %w", t.Name, err)
+			}
+			return nil, err
+		}
+
+		for j := range res.Actions {
+			res.Actions[j].Tailnet = t.Name
+			res.Actions[j].DeviceID = qualifyDeviceID(t.Name, res.Actions[j].DeviceID)
+		}
+		actions = append(actions, res.Actions...)
+	}
+
+	slices.SortStableFunc(actions, func(a, b HistoryAction) int { return b.Time.Compare(a.Time) })
+	return actions[:min(limit, len(actions))], nil
+}
+
+// handleRecentCommand replies ephemerally with the most recent approve and
+// decline actions, for a quick audit without scrolling the channel.
+func handleRecentCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
+	if !isApprover(cfg, i.Member) {
+		respondNotApprover(s, i)
+		return
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	actions, err := fetchRecentActions(cfg, httpClient, recentActionsLimit)
+	if err != nil {
+		slog.Error("Failed to fetch history", "error", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: ptr(apiErrorMessage("Failed to fetch recent actions", err)),
+		})
+		return
+	}
+	if len(actions) == 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: ptr("No approve or decline actions since the API last started."),
+		})
+		return
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{recentActionsEmbed(actions)},
+	})
+}
+
+// recentActionsEmbed lists actions as one embed field each.
+func recentActionsEmbed(actions []HistoryAction) *discordgo.MessageEmbed {
+	fields := make([]*discordgo.MessageEmbedField, 0, len(actions))
+	for _, a := range actions {
+		title := "✅ Approved"
+		detail := "Tags: `" + strings.Join(a.Tags, "`, `") + "`"
+		if a.Type == "device_declined" {
+			title = "❌ Declined"
+			detail = "Reason: " + declineReasonLabel(a.Reason)
+		}
+
+		device := cmp.Or(a.Name, a.DeviceID)
+		if a.Tailnet != "" {
+			device = a.Tailnet + ": " + device
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  truncate(fmt.Sprintf("%s • %s", title, device), 256),
+			Value: fmt.Sprintf("%s\nBy %s <t:%d:R>", detail, cmp.Or(a.Actor, "unknown"), a.Time.Unix()),
+		})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:  fmt.Sprintf("Last %d actions", len(actions)),
+		Fields: fields,
+	}
+}
@@ -1,33 +1,148 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 type Config struct {
-	BotToken       string
-	APIURL         string
+	BotToken string
+	APIURLs  []string // tried in order; later entries are failover targets
+	// Tailnets, when set, are separate API deployments for several tailnets.
+	// Device IDs are then prefixed with the tailnet name; see qualifyDeviceID.
+	Tailnets       []tailnetTarget
 	ChannelID      string
+	ThreadID       string
 	GuildID        string
 	PollInterval   time.Duration
 	MentionUserIDs []string
+	WebhookSecret  string
+	// HMACSecret signs API requests in X-Signature; it must match the
+	// API's HMAC_SECRET.
+	HMACSecret  string
+	WebhookPort string
+	HealthPort  string // empty disables the /readyz server
+	NamePrefix  string
+	SendDelay   time.Duration
+	MaxMessages int
+	// AuthorizeDevices also lists devices waiting for authorization and
+	// authorizes them when they are approved.
+	AuthorizeDevices bool
+	MessageTemplate  *template.Template
+	DualApprovalTags []string
+	ApproverRoleIDs  []string
+	// NotifyChannelID receives automated, non-interactive notifications.
+	NotifyChannelID string
+	// APIClientTimeout bounds each request to the API. It should exceed the
+	// API's HANDLER_TIMEOUT (45s by default) so retried Tailscale calls are
+	// not cut short.
+	APIClientTimeout   time.Duration
+	CommandName        string
+	CommandDescription string
+	// CommandPermissions is the Discord permission a member needs to see and
+	// run the approver commands. Nil leaves them open to everyone.
+	CommandPermissions *int64
+	// SkipTagSelection applies DefaultTags as soon as Approve is clicked,
+	// without showing the tag select menu.
+	SkipTagSelection bool
+	DefaultTags      []string
+	// TagProfiles are named tag bundles offered instead of individual tags.
+	TagProfiles map[string][]string
+	// SnoozeDuration is how long the Snooze button hides a device.
+	SnoozeDuration time.Duration
+	// AutoApproveRules approve matching devices without review.
+	AutoApproveRules []autoApproveRule
+	// TagDescriptions are shown under each tag in the tag select menu, and
+	// TagMenuPlaceholder replaces the menu's placeholder text.
+	TagDescriptions    map[string]string
+	TagMenuPlaceholder string
+	// ChannelRouting sends approval prompts for matching device names to
+	// other channels than ChannelID.
+	ChannelRouting []channelRoute
+	// DailyThread posts approval prompts into a new thread under ChannelID each day.
+	DailyThread bool
+	// TLSClientCertFile and TLSClientKeyFile are presented to the API for
+	// mutual TLS. TLSCAFile, when set, is trusted for the API's certificate.
+	TLSClientCertFile string
+	TLSClientKeyFile  string
+	TLSCAFile         string
+	// EscalateAfter re-pings approvers about a prompt left unanswered this
+	// long; EscalateAdminAfter DMs EscalateAdminUserID. Zero disables each.
+	EscalateAfter       time.Duration
+	EscalateAdminAfter  time.Duration
+	EscalateAdminUserID string
+	// AdminConsoleURL is linked from the too-many-pending warning.
+	AdminConsoleURL string
 }
 
+// defaultAdminConsoleURL is the machines page of the Tailscale admin console.
+const defaultAdminConsoleURL = "https://login.tailscale.com/admin/machines"
+
+// defaultMessageTemplate renders the approval message for a PendingDevice.
+const defaultMessageTemplate = "**{{if .Authorized}}New device pending approval{{else}}New device pending authorization{{end}}**{{if .Tailnet}}\nTailnet: `{{.Tailnet}}`{{end}}\nDevice: `{{.DisplayName}}`\nID: `{{.ID}}`"
+
 type PendingDevice struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ShortName  string `json:"short_name"`
+	Hostname   string `json:"hostname"`
+	User       string `json:"user"`
+	Authorized bool   `json:"authorized"`
+	NodeKey    string `json:"node_key"`
+	OS         string `json:"os"`
+	// SuggestedTags are preselected in the tag select menu.
+	SuggestedTags []string `json:"suggested_tags"`
+	// Tailnet is the TAILNETS name the device was found in, empty without TAILNETS.
+	Tailnet string `json:"-"`
+}
+
+// DisplayName identifies the device unambiguously as "host (user@domain)",
+// using the MagicDNS name, or the OS hostname when the name is not set.
+func (d PendingDevice) DisplayName() string {
+	host := d.Name
+	if host == "" {
+		host = d.Hostname
+	}
+	if d.User == "" {
+		return host
+	}
+	return fmt.Sprintf("%s (%s)", host, d.User)
+}
+
+// DeviceInfo is the live device detail returned by GET /devices/{deviceID}.
+type DeviceInfo struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Authorized bool      `json:"authorized"`
+	Tags       []string  `json:"tags"`
+	OS         string    `json:"os"`
+	Addresses  []string  `json:"addresses"`
+	LastSeen   time.Time `json:"last_seen"`
+	Tailnet    string    `json:"-"`
+}
+
+type DevicesResponse struct {
+	Devices []DeviceInfo `json:"devices"`
 }
 
 type PendingDevicesResponse struct {
@@ -39,25 +154,145 @@ type TagsResponse struct {
 }
 
 type ApproveRequest struct {
-	Tags []string `json:"tags"`
+	Tags              []string `json:"tags"`
+	Authorize         bool     `json:"authorize,omitempty"`
+	KeyExpiryDisabled *bool    `json:"key_expiry_disabled,omitempty"`
+	Actor             string   `json:"actor,omitempty"`
 }
 
+type ApproveResponse struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// isDryRunApproval reports whether an approve response says the API's dry-run
+// mode left the device untouched. A body that is not an ApproveResponse counts
+// as a real approval.
+func isDryRunApproval(resp *http.Response) bool {
+	var body ApproveResponse
+	return json.NewDecoder(resp.Body).Decode(&body) == nil && body.DryRun
+}
+
+// commandNamePattern matches the names Discord accepts for slash commands.
+var commandNamePattern = regexp.MustCompile(`^[-_a-z0-9]{1,32}$`)
+
+// loadConfig reads the configuration from the environment. All problems are
+// collected and returned together so they can be fixed in one pass.
 func loadConfig() (Config, error) {
+	var errs []error
+
 	botToken := os.Getenv("DISCORD_BOT_TOKEN")
 	if botToken == "" {
-		return Config{}, errors.New("DISCORD_BOT_TOKEN is required")
+		errs = append(errs, errors.New("DISCORD_BOT_TOKEN is required"))
 	}
 
-	apiURL := os.Getenv("API_URL")
-	if apiURL == "" {
-		apiURL = "http://localhost:8080"
+	var apiURLs []string
+	for _, u := range strings.Split(os.Getenv("API_URLS"), ",") {
+		if trimmed := strings.TrimSpace(u); trimmed != "" {
+			apiURLs = append(apiURLs, strings.TrimSuffix(trimmed, "/"))
+		}
+	}
+	if len(apiURLs) == 0 {
+		apiURL := os.Getenv("API_URL")
+		if apiURL == "" {
+			apiURL = "http://localhost:8080"
+		}
+		apiURLs = []string{apiURL}
+	}
+
+	tailnets, err := parseTailnets(os.Getenv("TAILNETS")) // optional: empty = one tailnet at API_URL(S)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("TAILNETS: %w", err))
+	}
+	if len(tailnets) > 0 {
+		// Calls not tied to a device, such as the startup tag check, go to the first tailnet.
+		apiURLs = tailnets[0].APIURLs
 	}
 
 	channelID := os.Getenv("DISCORD_CHANNEL_ID")
 	if channelID == "" {
-		return Config{}, errors.New("DISCORD_CHANNEL_ID is required")
+		errs = append(errs, errors.New("DISCORD_CHANNEL_ID is required"))
 	}
 
+	threadID := os.Getenv("DISCORD_THREAD_ID") // optional: empty = post to channel directly
+
+	commandName := os.Getenv("COMMAND_NAME")
+	if commandName == "" {
+		commandName = "tailscale-approve"
+	}
+	if !commandNamePattern.MatchString(commandName) {
+		errs = append(errs, errors.New("COMMAND_NAME must be 1-32 lowercase letters, digits, '-' or '_'"))
+	}
+
+	commandDescription := os.Getenv("COMMAND_DESCRIPTION")
+	if commandDescription == "" {
+		commandDescription = "Check and approve pending Tailscale devices"
+	}
+	if len(commandDescription) > 100 {
+		errs = append(errs, errors.New("COMMAND_DESCRIPTION must be at most 100 characters"))
+	}
+
+	commandPermissions, err := parseCommandPermissions(os.Getenv("COMMAND_PERMISSIONS")) // optional: empty = everyone
+	if err != nil {
+		errs = append(errs, fmt.Errorf("COMMAND_PERMISSIONS %w", err))
+	}
+
+	var defaultTags []string
+	for _, tag := range strings.Split(os.Getenv("DEFAULT_TAGS"), ",") {
+		if trimmed := strings.TrimSpace(tag); trimmed != "" {
+			defaultTags = append(defaultTags, trimmed)
+		}
+	}
+	for _, tag := range defaultTags {
+		if !strings.HasPrefix(tag, "tag:") {
+			errs = append(errs, fmt.Errorf("DEFAULT_TAGS contains %q, tags must start with \"tag:\"", tag))
+		}
+	}
+
+	skipTagSelection := os.Getenv("SKIP_TAG_SELECTION") == "true"
+	if skipTagSelection && len(defaultTags) == 0 {
+		errs = append(errs, errors.New("DEFAULT_TAGS is required when SKIP_TAG_SELECTION is true"))
+	}
+
+	tagProfiles, err := parseTagProfiles(os.Getenv("TAG_PROFILES")) // optional: empty = pick individual tags
+	if err != nil {
+		errs = append(errs, fmt.Errorf("TAG_PROFILES %w", err))
+	}
+
+	tagDescriptions, err := parseTagDescriptions(os.Getenv("TAG_DESCRIPTIONS")) // optional: empty = no descriptions
+	if err != nil {
+		errs = append(errs, fmt.Errorf("TAG_DESCRIPTIONS %w", err))
+	}
+
+	tagMenuPlaceholder := os.Getenv("TAG_MENU_PLACEHOLDER")
+	if tagMenuPlaceholder == "" {
+		tagMenuPlaceholder = "Select tags to apply..."
+	}
+	if len([]rune(tagMenuPlaceholder)) > maxSelectPlaceholder {
+		errs = append(errs, fmt.Errorf("TAG_MENU_PLACEHOLDER must be at most %d characters", maxSelectPlaceholder))
+	}
+
+	snoozeDuration := 24 * time.Hour
+	if durationStr := os.Getenv("SNOOZE_DURATION"); durationStr != "" {
+		parsed, err := time.ParseDuration(durationStr)
+		if err != nil || parsed <= 0 || parsed > 7*24*time.Hour {
+			errs = append(errs, errors.New("SNOOZE_DURATION must be a positive duration of at most 168h (e.g., 4h)"))
+		} else {
+			snoozeDuration = parsed
+		}
+	}
+
+	channelRouting, err := parseChannelRouting(os.Getenv("CHANNEL_ROUTING")) // optional: empty = everything to DISCORD_CHANNEL_ID
+	if err != nil {
+		errs = append(errs, fmt.Errorf("CHANNEL_ROUTING %w", err))
+	}
+
+	dailyThread := os.Getenv("DAILY_THREAD") == "true"
+	if dailyThread && threadID != "" {
+		errs = append(errs, errors.New("DAILY_THREAD and DISCORD_THREAD_ID cannot both be set"))
+	}
+
+	notifyChannelID := os.Getenv("NOTIFY_CHANNEL_ID") // optional: empty = same as approval messages
+
 	guildID := os.Getenv("DISCORD_GUILD_ID") // optional: empty = global command
 
 	var mentionUserIDs []string
@@ -73,23 +308,171 @@ func loadConfig() (Config, error) {
 	if pollIntervalStr := os.Getenv("POLL_INTERVAL"); pollIntervalStr != "" {
 		parsed, err := time.ParseDuration(pollIntervalStr)
 		if err != nil {
-			return Config{}, errors.New("POLL_INTERVAL must be a valid duration (e.g., 24h, 1h30m)")
+			errs = append(errs, errors.New("POLL_INTERVAL must be a valid duration (e.g., 24h, 1h30m)"))
 		}
 		pollInterval = parsed
 	}
 
+	webhookSecret := os.Getenv("WEBHOOK_SECRET") // optional: empty = webhook receiver disabled
+	hmacSecret := os.Getenv("HMAC_SECRET")       // optional: empty = API requests are not signed
+
+	webhookPort := os.Getenv("WEBHOOK_PORT")
+	if webhookPort == "" {
+		webhookPort = "8081"
+	}
+
+	healthPort := os.Getenv("HEALTH_PORT") // optional: empty = no /readyz server
+
+	namePrefix := os.Getenv("DEVICE_NAME_PREFIX") // optional: empty = all devices
+
+	sendDelay := 500 * time.Millisecond
+	if sendDelayStr := os.Getenv("MESSAGE_SEND_DELAY"); sendDelayStr != "" {
+		parsed, err := time.ParseDuration(sendDelayStr)
+		if err != nil {
+			errs = append(errs, errors.New("MESSAGE_SEND_DELAY must be a valid duration (e.g., 500ms, 1s)"))
+		}
+		sendDelay = parsed
+	}
+
+	maxMessages := 5
+	if maxMessagesStr := os.Getenv("MAX_MESSAGES_PER_CHECK"); maxMessagesStr != "" {
+		parsed, err := strconv.Atoi(maxMessagesStr)
+		if err != nil || parsed < 1 {
+			errs = append(errs, errors.New("MAX_MESSAGES_PER_CHECK must be a positive integer"))
+		}
+		maxMessages = parsed
+	}
+
+	apiClientTimeout := 50 * time.Second
+	if apiClientTimeoutStr := os.Getenv("API_CLIENT_TIMEOUT"); apiClientTimeoutStr != "" {
+		parsed, err := time.ParseDuration(apiClientTimeoutStr)
+		if err != nil || parsed <= 0 {
+			errs = append(errs, errors.New("API_CLIENT_TIMEOUT must be a positive duration (e.g., 50s, 1m)"))
+		}
+		apiClientTimeout = parsed
+	}
+
+	authorizeDevices := os.Getenv("AUTHORIZE_DEVICES") == "true"
+
+	messageTemplateStr := os.Getenv("MESSAGE_TEMPLATE")
+	if messageTemplateStr == "" {
+		messageTemplateStr = defaultMessageTemplate
+	}
+	messageTemplate, err := template.New("message").Option("missingkey=error").Parse(messageTemplateStr)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("MESSAGE_TEMPLATE must be a valid Go template: %w", err))
+	} else if err := messageTemplate.Execute(io.Discard, PendingDevice{}); err != nil {
+		errs = append(errs, fmt.Errorf("MESSAGE_TEMPLATE failed to render: %w", err))
+	}
+
+	var dualApprovalTags []string
+	for _, tag := range strings.Split(os.Getenv("DUAL_APPROVAL_TAGS"), ",") {
+		if trimmed := strings.TrimSpace(tag); trimmed != "" {
+			dualApprovalTags = append(dualApprovalTags, trimmed)
+		}
+	}
+
+	autoApproveRules, err := parseAutoApproveRules(os.Getenv("AUTO_APPROVE_RULES"), dualApprovalTags) // optional: empty = every device is reviewed
+	if err != nil {
+		errs = append(errs, fmt.Errorf("AUTO_APPROVE_RULES %w", err))
+	}
+
+	var approverRoleIDs []string
+	for _, id := range strings.Split(os.Getenv("APPROVER_ROLE_IDS"), ",") {
+		if trimmed := strings.TrimSpace(id); trimmed != "" {
+			approverRoleIDs = append(approverRoleIDs, trimmed)
+		}
+	}
+
+	tlsClientCertFile := os.Getenv("TLS_CLIENT_CERT_FILE") // optional: empty = no client certificate
+	tlsClientKeyFile := os.Getenv("TLS_CLIENT_KEY_FILE")
+	if (tlsClientCertFile == "") != (tlsClientKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CLIENT_CERT_FILE and TLS_CLIENT_KEY_FILE must be set together"))
+	}
+
+	var escalateAfter time.Duration // optional: 0 = no re-ping
+	if escalateAfterStr := os.Getenv("ESCALATE_AFTER"); escalateAfterStr != "" {
+		parsed, err := time.ParseDuration(escalateAfterStr)
+		if err != nil || parsed < 0 {
+			errs = append(errs, errors.New("ESCALATE_AFTER must be a valid duration (e.g., 4h)"))
+		}
+		escalateAfter = parsed
+	}
+
+	var escalateAdminAfter time.Duration // optional: 0 = no admin DM
+	if escalateAdminAfterStr := os.Getenv("ESCALATE_ADMIN_AFTER"); escalateAdminAfterStr != "" {
+		parsed, err := time.ParseDuration(escalateAdminAfterStr)
+		if err != nil || parsed < 0 {
+			errs = append(errs, errors.New("ESCALATE_ADMIN_AFTER must be a valid duration (e.g., 24h)"))
+		}
+		escalateAdminAfter = parsed
+	}
+
+	escalateAdminUserID := os.Getenv("ESCALATE_ADMIN_USER_ID")
+	if escalateAdminAfter > 0 && escalateAdminUserID == "" {
+		errs = append(errs, errors.New("ESCALATE_ADMIN_AFTER requires ESCALATE_ADMIN_USER_ID"))
+	}
+
+	adminConsoleURL := os.Getenv("ADMIN_CONSOLE_URL")
+	if adminConsoleURL == "" {
+		adminConsoleURL = defaultAdminConsoleURL
+	} else if u, err := url.Parse(adminConsoleURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, errors.New("ADMIN_CONSOLE_URL must be an absolute URL (e.g., https://login.tailscale.com/admin/machines)"))
+	}
+
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+
 	return Config{
-		BotToken:       botToken,
-		APIURL:         apiURL,
-		ChannelID:      channelID,
-		GuildID:        guildID,
-		PollInterval:   pollInterval,
-		MentionUserIDs: mentionUserIDs,
+		BotToken:            botToken,
+		APIURLs:             apiURLs,
+		Tailnets:            tailnets,
+		ChannelID:           channelID,
+		ThreadID:            threadID,
+		GuildID:             guildID,
+		PollInterval:        pollInterval,
+		MentionUserIDs:      mentionUserIDs,
+		WebhookSecret:       webhookSecret,
+		HMACSecret:          hmacSecret,
+		WebhookPort:         webhookPort,
+		HealthPort:          healthPort,
+		NamePrefix:          namePrefix,
+		SendDelay:           sendDelay,
+		MaxMessages:         maxMessages,
+		AuthorizeDevices:    authorizeDevices,
+		MessageTemplate:     messageTemplate,
+		DualApprovalTags:    dualApprovalTags,
+		ApproverRoleIDs:     approverRoleIDs,
+		NotifyChannelID:     notifyChannelID,
+		APIClientTimeout:    apiClientTimeout,
+		ChannelRouting:      channelRouting,
+		DailyThread:         dailyThread,
+		CommandName:         commandName,
+		CommandDescription:  commandDescription,
+		CommandPermissions:  commandPermissions,
+		SkipTagSelection:    skipTagSelection,
+		DefaultTags:         defaultTags,
+		TagProfiles:         tagProfiles,
+		SnoozeDuration:      snoozeDuration,
+		AutoApproveRules:    autoApproveRules,
+		TagDescriptions:     tagDescriptions,
+		TagMenuPlaceholder:  tagMenuPlaceholder,
+		TLSClientCertFile:   tlsClientCertFile,
+		TLSClientKeyFile:    tlsClientKeyFile,
+		TLSCAFile:           os.Getenv("TLS_CA_FILE"), // optional: empty = system roots
+		EscalateAfter:       escalateAfter,
+		EscalateAdminAfter:  escalateAdminAfter,
+		EscalateAdminUserID: escalateAdminUserID,
+		AdminConsoleURL:     adminConsoleURL,
 	}, nil
 }
 
 func main() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})))
+	watchLogLevelSignal()
+
+	checkOnly := configCheckRequested()
 
 	cfg, err := loadConfig()
 	if err != nil {
@@ -97,44 +480,122 @@ func main() {
 		os.Exit(1)
 	}
 
+	// In check mode, stop before connecting to Discord or the API.
+	if checkOnly {
+		printConfig(os.Stdout, cfg)
+		fmt.Println("Config OK")
+		return
+	}
+
 	dg, err := discordgo.New("Bot " + cfg.BotToken)
 	if err != nil {
 		slog.Error("Failed to create Discord session", "error", err)
 		os.Exit(1)
 	}
 
+	httpClient, err := newAPIHTTPClient(cfg)
+	if err != nil {
+		slog.Error("Failed to set up API client", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.SkipTagSelection || len(cfg.TagProfiles) > 0 {
+		if err := checkConfiguredTags(cfg, httpClient); err != nil {
+			slog.Error("Invalid config", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	registerSessionHandlers(dg, cfg, httpClient)
+
 	if err := dg.Open(); err != nil {
 		slog.Error("Failed to open Discord connection", "error", err)
 		os.Exit(1)
 	}
 	defer dg.Close()
-
-	// Register slash command
-	cmd := &discordgo.ApplicationCommand{
-		Name:        "tailscale-approve",
-		Description: "Check and approve pending Tailscale devices",
+	session.ready.Store(true)
+
+	// Register slash commands. Approver commands carry COMMAND_PERMISSIONS so
+	// Discord hides them from members without it; read-only ones do not.
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:                     cfg.CommandName,
+			Description:              cfg.CommandDescription,
+			DefaultMemberPermissions: cfg.CommandPermissions,
+		},
+		{
+			Name:        "tailscale-device",
+			Description: "Show the approval status of a Tailscale device",
+			Options: append([]*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "Device name (or the start of it)",
+					Required:    true,
+				},
+			}, tailnetOptions(cfg)...),
+		},
+		{
+			Name:                     "tailscale-refresh",
+			Description:              "Check for pending Tailscale devices now and post approval requests",
+			DefaultMemberPermissions: cfg.CommandPermissions,
+		},
+		{
+			Name:                     "tailscale-pause",
+			Description:              "Pause automatic checks for pending Tailscale devices",
+			DefaultMemberPermissions: cfg.CommandPermissions,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "duration",
+					Description: "Resume automatically after this long (e.g. 30m, 2h)",
+				},
+			},
+		},
+		{
+			Name:                     "tailscale-resume",
+			Description:              "Resume automatic checks for pending Tailscale devices",
+			DefaultMemberPermissions: cfg.CommandPermissions,
+		},
+		{
+			Name:                     "tailscale-recent",
+			Description:              "Show the most recent approve and decline actions",
+			DefaultMemberPermissions: cfg.CommandPermissions,
+		},
+		{
+			Name:                     "tailscale-config",
+			Description:              "Show the bot's effective configuration with secrets redacted",
+			DefaultMemberPermissions: cfg.CommandPermissions,
+		},
 	}
 
-	registeredCmd, err := dg.ApplicationCommandCreate(dg.State.User.ID, cfg.GuildID, cmd)
-	if err != nil {
-		slog.Error("Failed to register slash command", "error", err)
+	if err := registerCommands(dg, cfg.GuildID, commands); err != nil {
+		slog.Error("Failed to register slash commands", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("Registered slash command", "name", registeredCmd.Name, "guildID", cfg.GuildID)
-
-	httpClient := &http.Client{Timeout: 30 * time.Second}
 
-	// Handle slash command
+	// Handle slash commands
 	dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		if i.Type != discordgo.InteractionApplicationCommand {
 			return
 		}
 
-		if i.ApplicationCommandData().Name != "tailscale-approve" {
-			return
+		switch i.ApplicationCommandData().Name {
+		case cfg.CommandName:
+			handleSlashCommand(s, i, cfg, httpClient)
+		case "tailscale-device":
+			handleDeviceCommand(s, i, cfg, httpClient)
+		case "tailscale-refresh":
+			handleRefreshCommand(s, i, cfg, httpClient)
+		case "tailscale-pause":
+			handlePauseCommand(s, i, cfg)
+		case "tailscale-resume":
+			handleResumeCommand(s, i, cfg)
+		case "tailscale-recent":
+			handleRecentCommand(s, i, cfg, httpClient)
+		case "tailscale-config":
+			handleConfigCommand(s, i, cfg)
 		}
-
-		handleSlashCommand(s, i, cfg, httpClient)
 	})
 
 	// Handle button and select menu interactions
@@ -143,15 +604,25 @@ func main() {
 			return
 		}
 
-		customID := i.MessageComponentData().CustomID
-		if strings.HasPrefix(customID, "select_tags:") {
+		action, _, _ := decodeCustomID(i.MessageComponentData().CustomID)
+		switch action {
+		case "select_tags":
 			handleSelectMenu(s, i, cfg, httpClient)
-		} else {
+		case "select_profile":
+			handleProfileSelect(s, i, cfg, httpClient)
+		case "select_decline":
+			handleDeclineReasonSelect(s, i, cfg, httpClient)
+		default:
 			handleButtonClick(s, i, cfg, httpClient)
 		}
 	})
 
-	slog.Info("Discord bot started", "apiURL", cfg.APIURL, "pollInterval", cfg.PollInterval)
+	// Re-post approval messages that were deleted while the device is still pending
+	dg.AddHandler(func(s *discordgo.Session, m *discordgo.MessageDelete) {
+		handleMessageDelete(s, m, cfg, httpClient)
+	})
+
+	slog.Info("Discord bot started", "apiURLs", cfg.APIURLs, "pollInterval", cfg.PollInterval)
 
 	// Start automatic polling loop
 	go func() {
@@ -159,10 +630,37 @@ func main() {
 		defer ticker.Stop()
 
 		for range ticker.C {
-			runScheduledCheck(dg, cfg, httpClient)
+			runAutomaticCheck(dg, cfg, httpClient)
 		}
 	}()
 
+	if cfg.EscalateAfter > 0 || cfg.EscalateAdminAfter > 0 {
+		go runEscalationSweeper(dg, cfg, httpClient)
+	}
+
+	// Start webhook receiver so Tailscale events trigger an immediate check
+	if cfg.WebhookSecret != "" {
+		server := &http.Server{Addr: ":" + cfg.WebhookPort, Handler: newWebhookHandler(dg, cfg, httpClient)}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Webhook server error", "error", err)
+			}
+		}()
+		defer server.Shutdown(context.Background())
+		slog.Info("Webhook receiver started", "port", cfg.WebhookPort)
+	}
+
+	if cfg.HealthPort != "" {
+		server := &http.Server{Addr: ":" + cfg.HealthPort, Handler: newHealthHandler()}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Health server error", "error", err)
+			}
+		}()
+		defer server.Shutdown(context.Background())
+		slog.Info("Health server started", "port", cfg.HealthPort)
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
@@ -181,53 +679,260 @@ func buildMentionString(userIDs []string) string {
 	return strings.Join(mentions, " ") + "\n"
 }
 
-func runScheduledCheck(s *discordgo.Session, cfg Config, httpClient *http.Client) {
+// targetChannelID returns the channel approval messages are posted to.
+// Discord treats a thread as a channel, so the thread ID is used directly when set.
+func targetChannelID(cfg Config) string {
+	if cfg.ThreadID != "" {
+		return cfg.ThreadID
+	}
+	return cfg.ChannelID
+}
+
+// notifyChannelID returns the channel automated notifications (such as the
+// too-many-devices warning) are posted to, falling back to targetChannelID.
+func notifyChannelID(cfg Config) string {
+	if cfg.NotifyChannelID != "" {
+		return cfg.NotifyChannelID
+	}
+	return targetChannelID(cfg)
+}
+
+// checkRunning guards runScheduledCheck so a slow check is not overlapped by
+// the next tick or a webhook, which would post duplicate messages.
+var checkRunning sync.Mutex
+
+// checkRerun is set when a check is requested while another is running, so
+// the running check does one more pass for devices that arrived meanwhile.
+// checkRerunForce carries a forced request over to that pass.
+var checkRerun, checkRerunForce atomic.Bool
+
+// lastCheckVersion is the pending-devices version whose messages were all
+// posted by a scheduled check. An unchanged version can then be skipped.
+// Guarded by checkRunning.
+var lastCheckVersion string
+
+var (
+	errCheckInProgress     = errors.New("a check is already running; another will run as soon as it finishes")
+	errSessionDisconnected = errors.New("discord session is not connected")
+)
+
+// runScheduledCheck fetches pending devices and posts approval messages for
+// them. It returns the number of pending devices found. force posts them even
+// if the pending devices are unchanged since the last check.
+func runScheduledCheck(s *discordgo.Session, cfg Config, httpClient *http.Client, force bool) (int, error) {
+	if !checkRunning.TryLock() {
+		if force {
+			checkRerunForce.Store(true)
+		}
+		checkRerun.Store(true)
+		slog.Info("Check requested while another is running, it will run again when done")
+		return 0, errCheckInProgress
+	}
+	defer checkRunning.Unlock()
+
+	for {
+		count, err := runCheckLocked(s, cfg, httpClient, force)
+		if !checkRerun.Swap(false) {
+			return count, err
+		}
+		force = checkRerunForce.Swap(false)
+		slog.Info("Running follow-up check requested during the previous one")
+	}
+}
+
+// runCheckLocked is one pass of runScheduledCheck. Guarded by checkRunning.
+func runCheckLocked(s *discordgo.Session, cfg Config, httpClient *http.Client, force bool) (int, error) {
+	if !session.ready.Load() {
+		slog.Warn("Skipping scheduled check: Discord session is not connected")
+		session.missedCheck.Store(true)
+		return 0, errSessionDisconnected
+	}
+
 	slog.Info("Running scheduled check")
 
-	pending, err := fetchPendingDevices(cfg, httpClient)
+	pending, version, err := fetchPendingDevices(cfg, httpClient)
+	health.recordCheck(err, time.Now())
 	if err != nil {
 		slog.Error("Scheduled check failed", "error", err)
-		return
+		return 0, err
+	}
+
+	if !force && version != "" && version == lastCheckVersion {
+		slog.Info("Pending devices unchanged since last check, skipping", "count", len(pending))
+		return len(pending), nil
 	}
+	// Cleared until this version's messages are all posted, so a failed post
+	// is retried by the next check.
+	lastCheckVersion = ""
 
 	if len(pending) == 0 {
 		slog.Info("No pending devices found")
-		return
+		lastCheckVersion = version
+		return 0, nil
 	}
 
 	mentionPrefix := buildMentionString(cfg.MentionUserIDs)
 
+	// Checked before auto-approval, so a burst of devices matching a broad
+	// rule is shown to a human rather than approved unseen.
 	if len(pending) >= 3 {
-		s.ChannelMessageSend(cfg.ChannelID, fmt.Sprintf("%sWarning: %d pending devices found. This is unusual. Please check the Tailscale admin console.", mentionPrefix, len(pending)))
-		return
+		if _, err := s.ChannelMessageSend(notifyChannelID(cfg), mentionPrefix+tooManyPendingWarning(cfg, len(pending))); err != nil {
+			slog.Error("Failed to send too-many-pending warning", "error", err)
+			return len(pending), nil
+		}
+		lastCheckVersion = version
+		return len(pending), nil
+	}
+
+	manual := autoApprove(s, cfg, httpClient, pending)
+	if len(manual) == 0 {
+		lastCheckVersion = version
+		return len(pending), nil
+	}
+
+	if err := sendDeviceApprovalMessages(s, cfg, manual, mentionPrefix); err != nil {
+		return len(pending), nil
+	}
+	// Devices over MaxMessages are deferred, so the next check must not be skipped.
+	if len(manual) <= cfg.MaxMessages {
+		lastCheckVersion = version
+	}
+	return len(pending), nil
+}
+
+// tooManyPendingWarning is posted instead of approval messages when an
+// unusual number of devices is pending. The link is wrapped in <> so Discord
+// does not embed a preview of the login page.
+func tooManyPendingWarning(cfg Config, count int) string {
+	return fmt.Sprintf("Warning: %d pending devices found. This is unusual. Please check the Tailscale admin console: <%s>", count, cfg.AdminConsoleURL)
+}
+
+// sendDeviceApprovalMessages posts approval messages with a delay between
+// sends and at most cfg.MaxMessages per call, to stay within Discord's
+// channel rate limits. Devices over the cap are picked up by the next check.
+// Devices matching CHANNEL_ROUTING go to their routed channel, the rest to
+// the default channel or today's thread. It returns the failed sends, which
+// are already logged.
+func sendDeviceApprovalMessages(s *discordgo.Session, cfg Config, devices []PendingDevice, mentionPrefix string) error {
+	if len(devices) > cfg.MaxMessages {
+		slog.Warn("Too many approval messages for one check, deferring the rest", "sending", cfg.MaxMessages, "deferred", len(devices)-cfg.MaxMessages)
+		devices = devices[:cfg.MaxMessages]
+	}
+
+	var errs []error
+	for idx, device := range devices {
+		if idx > 0 {
+			time.Sleep(cfg.SendDelay)
+		}
+		channelID, routed := routedChannelID(cfg.ChannelRouting, device)
+		if !routed {
+			channelID = targetChannelID(cfg)
+			if cfg.DailyThread {
+				channelID = approvalThread.channelFor(s, cfg.ChannelID, time.Now())
+			}
+		}
+		if err := sendDeviceApprovalMessageWithMention(s, cfg, channelID, device, mentionPrefix); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetchPendingDevices returns the pending devices of every tailnet, with
+// their IDs qualified by tailnet. The returned version changes whenever the
+// result does, and is empty if any API sent no ETag.
+func fetchPendingDevices(cfg Config, httpClient *http.Client) ([]PendingDevice, string, error) {
+	var pending []PendingDevice
+	var versions []string
+	for _, t := range cfg.tailnetTargets() {
+		devices, version, err := fetchTailnetPendingDevices(cfg.forTailnet(t.Name), httpClient)
+		if err != nil {
+			if t.Name != "" {
+				err = fmt.Errorf("tailnet %s: %w", t.Name, err)
+			}
+			return nil, "", err
+		}
+		// Copy before qualifying IDs, as the slice may be shared with pendingResponses.
+		devices = slices.Clone(devices)
+		for j := range devices {
+			devices[j].Tailnet = t.Name
+			devices[j].ID = qualifyDeviceID(t.Name, devices[j].ID)
+		}
+		pending = append(pending, devices...)
+		versions = append(versions, version)
 	}
 
-	for _, device := range pending {
-		sendDeviceApprovalMessageWithMention(s, cfg.ChannelID, device, mentionPrefix)
+	if slices.Contains(versions, "") {
+		return pending, "", nil
 	}
+	return pending, strings.Join(versions, ";"), nil
 }
 
-func fetchPendingDevices(cfg Config, httpClient *http.Client) ([]PendingDevice, error) {
-	resp, err := httpClient.Get(cfg.APIURL + "/pending-devices")
+// fetchTailnetPendingDevices returns untagged devices, plus devices waiting
+// for authorization when cfg.AuthorizeDevices is set, from one tailnet's API.
+func fetchTailnetPendingDevices(cfg Config, httpClient *http.Client) ([]PendingDevice, string, error) {
+	pending, version, err := fetchPendingDevicesByState(cfg, httpClient, "untagged")
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	if cfg.AuthorizeDevices {
+		unauthorized, unauthorizedVersion, err := fetchPendingDevicesByState(cfg, httpClient, "unauthorized")
+		if err != nil {
+			return nil, "", err
+		}
+		pending = append(pending, unauthorized...)
+		if version == "" || unauthorizedVersion == "" {
+			version = ""
+		} else {
+			version += "," + unauthorizedVersion
+		}
+	}
+
+	return pending, version, nil
+}
+
+// fetchPendingDevicesByState sends the previous ETag for the same query, and
+// reuses the cached devices when the API answers 304 Not Modified.
+func fetchPendingDevicesByState(cfg Config, httpClient *http.Client, state string) ([]PendingDevice, string, error) {
+	query := url.Values{"state": {state}}
+	if cfg.NamePrefix != "" {
+		query.Set("name_prefix", cfg.NamePrefix)
+	}
+	path := "/pending-devices?" + query.Encode()
+
+	header := http.Header{}
+	cacheKey := strings.Join(cfg.APIURLs, ",") + path
+	cached, hasCached := pendingResponses.get(cacheKey)
+	if hasCached {
+		header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := apiDo(cfg, httpClient, http.MethodGet, path, nil, header)
+	if err != nil {
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		return slices.Clone(cached.devices), cached.etag, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("controller returned status %d", resp.StatusCode)
+		return nil, "", fmt.Errorf("controller returned status %d", resp.StatusCode)
 	}
 
 	var res PendingDevicesResponse
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return res.PendingDevices, nil
+	etag := resp.Header.Get("ETag")
+	pendingResponses.put(cacheKey, pendingCacheEntry{etag: etag, devices: res.PendingDevices})
+	return res.PendingDevices, etag, nil
 }
 
 func fetchAvailableTags(cfg Config, httpClient *http.Client) ([]string, error) {
-	resp, err := httpClient.Get(cfg.APIURL + "/tags")
+	resp, err := apiGet(cfg, httpClient, "/tags")
 	if err != nil {
 		return nil, err
 	}
@@ -245,6 +950,78 @@ func fetchAvailableTags(cfg Config, httpClient *http.Client) ([]string, error) {
 	return res.Tags, nil
 }
 
+// fetchDevicesByName looks up devices by name in the named tailnet, or in
+// every tailnet when tailnet is empty.
+func fetchDevicesByName(cfg Config, httpClient *http.Client, tailnet, name string) ([]DeviceInfo, error) {
+	var devices []DeviceInfo
+	for _, t := range cfg.tailnetTargets() {
+		if tailnet != "" && t.Name != tailnet {
+			continue
+		}
+		found, err := fetchTailnetDevicesByName(cfg.forTailnet(t.Name), httpClient, name)
+		if err != nil {
+			if t.Name != "" {
+				err = fmt.Errorf("tailnet %s: %w", t.Name, err)
+			}
+			return nil, err
+		}
+		for j := range found {
+			found[j].Tailnet = t.Name
+			found[j].ID = qualifyDeviceID(t.Name, found[j].ID)
+		}
+		devices = append(devices, found...)
+	}
+	return devices, nil
+}
+
+func fetchTailnetDevicesByName(cfg Config, httpClient *http.Client, name string) ([]DeviceInfo, error) {
+	resp, err := apiGet(cfg, httpClient, "/devices?"+url.Values{"name": {name}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("controller returned status %d", resp.StatusCode)
+	}
+
+	var res DevicesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+
+	return res.Devices, nil
+}
+
+var errDeviceNotFound = errors.New("device not found")
+
+// fetchDeviceInfo returns live details for a device ID from
+// fetchPendingDevices, asking the API of the device's tailnet.
+func fetchDeviceInfo(cfg Config, httpClient *http.Client, deviceID string) (DeviceInfo, error) {
+	tailnetCfg, id := cfg.forDevice(deviceID)
+	resp, err := apiGet(tailnetCfg, httpClient, "/devices/"+url.PathEscape(id))
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return DeviceInfo{}, errDeviceNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return DeviceInfo{}, fmt.Errorf("controller returned status %d", resp.StatusCode)
+	}
+
+	var info DeviceInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return DeviceInfo{}, err
+	}
+	info.ID = deviceID
+	info.Tailnet, _ = cfg.splitDeviceID(deviceID)
+
+	return info, nil
+}
+
 func handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
 	slog.Info("Slash command invoked", "user", i.Member.User.Username)
 
@@ -253,11 +1030,11 @@ func handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cf
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 	})
 
-	pending, err := fetchPendingDevices(cfg, httpClient)
+	pending, _, err := fetchPendingDevices(cfg, httpClient)
 	if err != nil {
 		slog.Error("Failed to get pending devices", "error", err)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content: ptr("Failed to get pending devices: " + err.Error()),
+			Content: ptr(apiErrorMessage("Failed to get pending devices", err)),
 		})
 		return
 	}
@@ -272,7 +1049,7 @@ func handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cf
 	// Too many devices warning
 	if len(pending) >= 3 {
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content: ptr(fmt.Sprintf("Warning: %d pending devices found. This is unusual. Please check the Tailscale admin console.", len(pending))),
+			Content: ptr(tooManyPendingWarning(cfg, len(pending))),
 		})
 		return
 	}
@@ -283,30 +1060,46 @@ func handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cf
 	})
 
 	// Send individual messages with buttons
-	for _, device := range pending {
-		sendDeviceApprovalMessage(s, cfg.ChannelID, device)
-	}
+	sendDeviceApprovalMessages(s, cfg, pending, "")
 }
 
-func sendDeviceApprovalMessage(s *discordgo.Session, channelID string, device PendingDevice) {
-	sendDeviceApprovalMessageWithMention(s, channelID, device, "")
+func sendDeviceApprovalMessage(s *discordgo.Session, cfg Config, channelID string, device PendingDevice) {
+	sendDeviceApprovalMessageWithMention(s, cfg, channelID, device, "")
 }
 
-func sendDeviceApprovalMessageWithMention(s *discordgo.Session, channelID string, device PendingDevice, mentionPrefix string) {
-	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-		Content: fmt.Sprintf("%s**New device pending approval**\nName: `%s`\nID: `%s`", mentionPrefix, device.Name, device.ID),
+func sendDeviceApprovalMessageWithMention(s *discordgo.Session, cfg Config, channelID string, device PendingDevice, mentionPrefix string) error {
+	device.ShortName = shortName(device.Name)
+
+	var content strings.Builder
+	if err := cfg.MessageTemplate.Execute(&content, device); err != nil {
+		slog.Error("Failed to render approval message", "device", device.Name, "error", err)
+		return err
+	}
+
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content: mentionPrefix + content.String(),
 		Components: []discordgo.MessageComponent{
 			discordgo.ActionsRow{
 				Components: []discordgo.MessageComponent{
 					discordgo.Button{
 						Label:    "Approve",
 						Style:    discordgo.SuccessButton,
-						CustomID: "approve:" + device.ID,
+						CustomID: encodeCustomID("approve", device.ID),
 					},
 					discordgo.Button{
 						Label:    "Decline",
 						Style:    discordgo.DangerButton,
-						CustomID: "decline:" + device.ID,
+						CustomID: encodeCustomID("decline", device.ID),
+					},
+					discordgo.Button{
+						Label:    "Info",
+						Style:    discordgo.SecondaryButton,
+						CustomID: encodeCustomID("info", device.ID),
+					},
+					discordgo.Button{
+						Label:    "Snooze",
+						Style:    discordgo.SecondaryButton,
+						CustomID: encodeCustomID("snooze", device.ID),
 					},
 				},
 			},
@@ -314,133 +1107,324 @@ func sendDeviceApprovalMessageWithMention(s *discordgo.Session, channelID string
 	})
 	if err != nil {
 		slog.Error("Failed to send approval message", "device", device.Name, "error", err)
+		return err
 	}
+	approvals.track(msg.ID, channelID, device, time.Now())
+	return nil
 }
 
 func handleButtonClick(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
-	customID := i.MessageComponentData().CustomID
-	parts := strings.SplitN(customID, ":", 2)
-	if len(parts) != 2 {
+	action, deviceID, ok := decodeCustomID(i.MessageComponentData().CustomID)
+	if !ok {
 		return
 	}
 
-	action := parts[0]
-	deviceID := parts[1]
-
 	slog.Info("Button clicked", "action", action, "deviceID", deviceID, "user", i.Member.User.Username)
 
 	switch action {
 	case "approve":
-		// Fetch available tags and show select menu
-		tags, err := fetchAvailableTags(cfg, httpClient)
-		if err != nil {
-			slog.Error("Failed to fetch tags", "error", err)
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "Failed to fetch available tags: " + err.Error(),
-					Flags:   discordgo.MessageFlagsEphemeral,
-				},
-			})
+		if cfg.SkipTagSelection {
+			approveWithDefaultTags(s, i, cfg, httpClient, deviceID)
 			return
 		}
 
-		// Build select menu options
-		options := make([]discordgo.SelectMenuOption, len(tags))
-		for idx, tag := range tags {
-			options[idx] = discordgo.SelectMenuOption{
-				Label: tag,
-				Value: tag,
-			}
+		if len(cfg.TagProfiles) > 0 {
+			showProfileSelectMenu(s, i, cfg, deviceID)
+			return
 		}
 
+		showTagSelectMenu(s, i, cfg, httpClient, deviceID)
+
+	case "advanced":
+		showTagSelectMenu(s, i, cfg, httpClient, deviceID)
+
+	case "decline":
+		showDeclineReasonMenu(s, i, deviceID)
+
+	case "info":
+		handleInfoButton(s, i, cfg, httpClient, deviceID)
+
+	case "snooze":
+		handleSnoozeButton(s, i, cfg, httpClient, deviceID)
+
+	case "confirm":
+		handleConfirmButton(s, i, cfg, httpClient, deviceID)
+
+	case "toggle_key_expiry":
+		handleKeyExpiryToggle(s, i, deviceID)
+
+	case "second_approve":
+		handleSecondApproval(s, i, cfg, httpClient, deviceID)
+
+	case "cancel":
+		approvals.untrack(i.Message.ID)
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseUpdateMessage,
 			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("**Select tags to apply**\nDevice ID: `%s`", deviceID),
-				Components: []discordgo.MessageComponent{
-					discordgo.ActionsRow{
-						Components: []discordgo.MessageComponent{
-							discordgo.SelectMenu{
-								CustomID:    "select_tags:" + deviceID,
-								Placeholder: "Select tags to apply...",
-								MinValues:   intPtr(1),
-								MaxValues:   len(options),
-								Options:     options,
-							},
+				Content:    "🚫 **Cancelled**",
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+	}
+}
+
+// showTagSelectMenu replaces the approval message with a menu of the
+// available tags for the device.
+func showTagSelectMenu(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client, deviceID string) {
+	// Fetch available tags and show select menu
+	tailnetCfg, _ := cfg.forDevice(deviceID)
+	tags, err := fetchAvailableTags(tailnetCfg, httpClient)
+	if err != nil {
+		slog.Error("Failed to fetch tags", "error", err)
+		respondEphemeral(s, i, apiErrorMessage("Failed to fetch available tags", err))
+		return
+	}
+
+	// Build select menu options, preselecting tags suggested for the device's owner
+	var suggested []string
+	if device, ok := approvals.lookup(i.Message.ID); ok {
+		suggested = device.SuggestedTags
+	}
+	options := make([]discordgo.SelectMenuOption, len(tags))
+	for idx, tag := range tags {
+		options[idx] = discordgo.SelectMenuOption{
+			Label:       tag,
+			Value:       tag,
+			Description: cfg.TagDescriptions[tag],
+			Default:     slices.Contains(suggested, tag),
+		}
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("**Select tags to apply**\nDevice ID: `%s`", deviceID),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.SelectMenu{
+							CustomID:    encodeCustomID("select_tags", deviceID),
+							Placeholder: cfg.TagMenuPlaceholder,
+							MinValues:   intPtr(1),
+							MaxValues:   len(options),
+							Options:     options,
 						},
 					},
-					discordgo.ActionsRow{
-						Components: []discordgo.MessageComponent{
-							discordgo.Button{
-								Label:    "Cancel",
-								Style:    discordgo.SecondaryButton,
-								CustomID: "cancel:" + deviceID,
-							},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Cancel",
+							Style:    discordgo.SecondaryButton,
+							CustomID: encodeCustomID("cancel", deviceID),
 						},
 					},
 				},
 			},
-		})
+		},
+	})
+}
 
-	case "decline":
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseDeferredMessageUpdate,
-		})
+// noTagsSelectedMessage asks the reviewer to pick again when an approval
+// arrives without tags.
+const noTagsSelectedMessage = "No tags selected, please choose at least one."
 
-		resp, err := httpClient.Post(cfg.APIURL+"/decline/"+deviceID, "application/json", nil)
-		if err != nil {
-			slog.Error("Failed to call controller", "error", err)
-			s.ChannelMessageSend(i.ChannelID, fmt.Sprintf("Failed to decline device: %s", err.Error()))
-			return
-		}
-		defer resp.Body.Close()
+func handleSelectMenu(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
+	action, deviceID, ok := decodeCustomID(i.MessageComponentData().CustomID)
+	if !ok || action != "select_tags" {
+		return
+	}
+	selectedTags := i.MessageComponentData().Values
+	if len(selectedTags) == 0 {
+		// Discord clients occasionally submit the menu without values; keep
+		// the menu up instead of letting /approve reject the empty list.
+		respondEphemeral(s, i, noTagsSelectedMessage)
+		return
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			slog.Error("Controller returned error", "status", resp.StatusCode)
-			s.ChannelMessageSend(i.ChannelID, fmt.Sprintf("Failed to decline device: %s", resp.Status))
-			return
-		}
+	slog.Info("Tags selected", "deviceID", deviceID, "tags", selectedTags, "user", i.Member.User.Username)
 
-		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content:    ptr(fmt.Sprintf("❌ **Declined** by %s", i.Member.User.Username)),
-			Components: &[]discordgo.MessageComponent{},
+	confirmations.put(i.Message.ID, pendingConfirmation{
+		DeviceID:  deviceID,
+		Tags:      selectedTags,
+		CreatedAt: time.Now(),
+	})
+
+	// Ask for confirmation before applying, to catch mis-selections
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: confirmationMessage(i.Message.ID, pendingConfirmation{DeviceID: deviceID, Tags: selectedTags}),
+	})
+}
+
+// confirmationMessage renders the confirm step for a tag selection, with a
+// toggle for disabling the device's key expiry.
+func confirmationMessage(messageID string, confirmation pendingConfirmation) *discordgo.InteractionResponseData {
+	deviceID := confirmation.DeviceID
+	deviceLabel := deviceID
+	if device, ok := approvals.lookup(messageID); ok {
+		deviceLabel = shortName(device.Name)
+	}
+
+	keyExpiry := "Key expiry: unchanged"
+	toggleLabel := "Disable key expiry"
+	if confirmation.KeyExpiryDisabled {
+		keyExpiry = "Key expiry: **disabled**"
+		toggleLabel = "Keep key expiry"
+	}
+
+	return &discordgo.InteractionResponseData{
+		Content: fmt.Sprintf("**About to apply** `%s` to `%s`\n%s\nDevice ID: `%s`", strings.Join(confirmation.Tags, "`, `"), deviceLabel, keyExpiry, deviceID),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Confirm",
+						Style:    discordgo.SuccessButton,
+						CustomID: encodeCustomID("confirm", deviceID),
+					},
+					discordgo.Button{
+						Label:    toggleLabel,
+						Style:    discordgo.SecondaryButton,
+						CustomID: encodeCustomID("toggle_key_expiry", deviceID),
+					},
+					discordgo.Button{
+						Label:    "Cancel",
+						Style:    discordgo.SecondaryButton,
+						CustomID: encodeCustomID("cancel", deviceID),
+					},
+				},
+			},
+		},
+	}
+}
+
+// handleKeyExpiryToggle flips whether confirming the selection also disables
+// the device's key expiry, and re-renders the confirm step.
+func handleKeyExpiryToggle(s *discordgo.Session, i *discordgo.InteractionCreate, deviceID string) {
+	confirmation, ok := confirmations.toggleKeyExpiry(i.Message.ID, deviceID)
+	if !ok {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    "⌛ **Confirmation expired**. Please run the approval again.",
+				Components: []discordgo.MessageComponent{},
+			},
 		})
+		return
+	}
 
-	case "cancel":
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: confirmationMessage(i.Message.ID, confirmation),
+	})
+}
+
+func handleConfirmButton(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client, deviceID string) {
+	confirmation, ok := confirmations.take(i.Message.ID, deviceID)
+	if !ok {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseUpdateMessage,
 			Data: &discordgo.InteractionResponseData{
-				Content:    "🚫 **Cancelled**",
+				Content:    "⌛ **Confirmation expired**. Please run the approval again.",
 				Components: []discordgo.MessageComponent{},
 			},
 		})
+		return
 	}
+
+	if requiresDualApproval(cfg, confirmation.Tags) {
+		requestSecondApproval(s, i, confirmation)
+		return
+	}
+
+	applyApproval(s, i, cfg, httpClient, deviceID, confirmation.Tags, confirmation.KeyExpiryDisabled, i.Member.User.Username)
 }
 
-func handleSelectMenu(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
-	customID := i.MessageComponentData().CustomID
-	parts := strings.SplitN(customID, ":", 2)
-	if len(parts) != 2 || parts[0] != "select_tags" {
+// approveWithDefaultTags approves a device with cfg.DefaultTags, skipping the
+// select menu and confirmation step. Dual approval still applies.
+func approveWithDefaultTags(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client, deviceID string) {
+	if requiresDualApproval(cfg, cfg.DefaultTags) {
+		requestSecondApproval(s, i, pendingConfirmation{DeviceID: deviceID, Tags: cfg.DefaultTags})
 		return
 	}
 
-	deviceID := parts[1]
-	selectedTags := i.MessageComponentData().Values
+	applyApproval(s, i, cfg, httpClient, deviceID, cfg.DefaultTags, false, i.Member.User.Username)
+}
 
-	slog.Info("Tags selected", "deviceID", deviceID, "tags", selectedTags, "user", i.Member.User.Username)
+// checkConfiguredTags verifies that DEFAULT_TAGS (when SKIP_TAG_SELECTION is
+// on) and every TAG_PROFILES tag are offered by the API of every tailnet. If
+// an API cannot be reached yet, its check is skipped with a warning.
+func checkConfiguredTags(cfg Config, httpClient *http.Client) error {
+	var errs []error
+	for _, t := range cfg.tailnetTargets() {
+		available, err := fetchAvailableTags(cfg.forTailnet(t.Name), httpClient)
+		if err != nil {
+			slog.Warn("Could not verify configured tags against the API", "tailnet", t.Name, "error", err)
+			continue
+		}
+
+		var tailnetErrs []error
+		if cfg.SkipTagSelection {
+			if missing := missingTags(cfg.DefaultTags, available); len(missing) > 0 {
+				tailnetErrs = append(tailnetErrs, fmt.Errorf("DEFAULT_TAGS are not available tags: %s", strings.Join(missing, ", ")))
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(cfg.TagProfiles)) {
+			if missing := missingTags(cfg.TagProfiles[name], available); len(missing) > 0 {
+				tailnetErrs = append(tailnetErrs, fmt.Errorf("TAG_PROFILES profile %q has tags that are not available: %s", name, strings.Join(missing, ", ")))
+			}
+		}
+		for _, err := range tailnetErrs {
+			if t.Name != "" {
+				err = fmt.Errorf("tailnet %s: %w", t.Name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// missingTags returns the tags that are not in available.
+func missingTags(tags, available []string) []string {
+	var missing []string
+	for _, tag := range tags {
+		if !slices.Contains(available, tag) {
+			missing = append(missing, tag)
+		}
+	}
+	return missing
+}
+
+// applyApproval calls the approve API and updates the approval message with
+// the result. disableKeyExpiry also turns off key expiry for the device;
+// otherwise key expiry is left as it is. approvedBy is shown as the approver
+// in the message and recorded in the API's history.
+func applyApproval(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client, deviceID string, selectedTags []string, disableKeyExpiry bool, approvedBy string) {
+	if len(selectedTags) == 0 {
+		respondEphemeral(s, i, noTagsSelectedMessage)
+		return
+	}
 
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredMessageUpdate,
 	})
 
 	// Call approve API with selected tags
-	reqBody, _ := json.Marshal(ApproveRequest{Tags: selectedTags})
-	resp, err := httpClient.Post(cfg.APIURL+"/approve/"+deviceID, "application/json", bytes.NewReader(reqBody))
+	req := ApproveRequest{Tags: selectedTags, Authorize: cfg.AuthorizeDevices, Actor: approvedBy}
+	if disableKeyExpiry {
+		req.KeyExpiryDisabled = &disableKeyExpiry
+	}
+	reqBody, _ := json.Marshal(req)
+	tailnetCfg, id := cfg.forDevice(deviceID)
+	resp, err := apiPost(tailnetCfg, httpClient, "/approve/"+id, reqBody)
 	if err != nil {
 		slog.Error("Failed to call controller", "error", err)
+		// The buttons are removed below, so the prompt is no longer open and
+		// must not be escalated.
+		approvals.untrack(i.Message.ID)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content:    ptr(fmt.Sprintf("Failed to approve device: %s", err.Error())),
+			Content:    ptr(apiErrorMessage("Failed to approve device", err)),
 			Components: &[]discordgo.MessageComponent{},
 		})
 		return
@@ -449,6 +1433,7 @@ func handleSelectMenu(s *discordgo.Session, i *discordgo.InteractionCreate, cfg
 
 	if resp.StatusCode != http.StatusOK {
 		slog.Error("Controller returned error", "status", resp.StatusCode)
+		approvals.untrack(i.Message.ID)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
 			Content:    ptr(fmt.Sprintf("Failed to approve device: %s", resp.Status)),
 			Components: &[]discordgo.MessageComponent{},
@@ -456,12 +1441,122 @@ func handleSelectMenu(s *discordgo.Session, i *discordgo.InteractionCreate, cfg
 		return
 	}
 
+	approvals.untrack(i.Message.ID)
+	if isDryRunApproval(resp) {
+		slog.Info("Dry run: device was not approved", "deviceID", deviceID, "tags", selectedTags)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content:    ptr(fmt.Sprintf("🧪 Dry run: the API is in dry-run mode, so `%s` was not approved (tags: `%s`).", deviceID, strings.Join(selectedTags, "`, `"))),
+			Components: &[]discordgo.MessageComponent{},
+		})
+		return
+	}
 	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-		Content:    ptr(fmt.Sprintf("✅ **Approved** by %s\nTags: `%s`", i.Member.User.Username, strings.Join(selectedTags, "`, `"))),
+		Content:    ptr(""),
+		Embeds:     &[]*discordgo.MessageEmbed{approvedEmbed(deviceID, selectedTags, approvedBy, time.Now())},
 		Components: &[]discordgo.MessageComponent{},
 	})
 }
 
+// approvedEmbedColor is the green used for the approved embed.
+const approvedEmbedColor = 0x2ecc71
+
+// approvedEmbed records an approval with its time, device, and approver in
+// the footer, so a screenshot of the message is a self-contained audit record.
+func approvedEmbed(deviceID string, tags []string, approvedBy string, approvedAt time.Time) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title: "✅ Approved",
+		Color: approvedEmbedColor,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Approved by", Value: approvedBy, Inline: true},
+			{Name: "Tags", Value: "`" + strings.Join(tags, "`, `") + "`", Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Approved %s by %s • Device ID: %s", approvedAt.UTC().Format("2006-01-02 15:04:05 MST"), approvedBy, deviceID),
+		},
+		Timestamp: approvedAt.Format(time.RFC3339),
+	}
+}
+
+// requiresDualApproval reports whether any of tags is configured to need two approvers.
+func requiresDualApproval(cfg Config, tags []string) bool {
+	for _, tag := range tags {
+		if slices.Contains(cfg.DualApprovalTags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestSecondApproval records the current user as the first approver and
+// asks for a second, different approver.
+func requestSecondApproval(s *discordgo.Session, i *discordgo.InteractionCreate, confirmation pendingConfirmation) {
+	confirmation.FirstApproverID = i.Member.User.ID
+	confirmation.FirstApproverName = i.Member.User.Username
+	confirmation.CreatedAt = time.Now()
+	confirmations.put(i.Message.ID, confirmation)
+
+	slog.Info("First approval recorded", "deviceID", confirmation.DeviceID, "tags", confirmation.Tags, "user", i.Member.User.Username)
+
+	keyExpiry := ""
+	if confirmation.KeyExpiryDisabled {
+		keyExpiry = "\nKey expiry: **disabled**"
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("🔐 **1/2 approvals** — approved by %s, needs a second approver\nTags: `%s`%s\nDevice ID: `%s`",
+				i.Member.User.Username, strings.Join(confirmation.Tags, "`, `"), keyExpiry, confirmation.DeviceID),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Approve (2/2)",
+							Style:    discordgo.SuccessButton,
+							CustomID: encodeCustomID("second_approve", confirmation.DeviceID),
+						},
+						discordgo.Button{
+							Label:    "Cancel",
+							Style:    discordgo.SecondaryButton,
+							CustomID: encodeCustomID("cancel", confirmation.DeviceID),
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func handleSecondApproval(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client, deviceID string) {
+	confirmation, ok := confirmations.take(i.Message.ID, deviceID)
+	if !ok || confirmation.FirstApproverID == "" {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    "⌛ **Approval expired**. Please run the approval again.",
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		return
+	}
+
+	if confirmation.FirstApproverID == i.Member.User.ID {
+		confirmations.put(i.Message.ID, confirmation)
+		respondEphemeral(s, i, "You already approved this device. A different reviewer must give the second approval.")
+		return
+	}
+
+	applyApproval(s, i, cfg, httpClient, deviceID, confirmation.Tags, confirmation.KeyExpiryDisabled,
+		fmt.Sprintf("%s and %s", confirmation.FirstApproverName, i.Member.User.Username))
+}
+
+// shortName strips the MagicDNS suffix from a device name, so
+// "host.tailnet.ts.net" and "host" both become "host".
+func shortName(name string) string {
+	host, _, _ := strings.Cut(name, ".")
+	return host
+}
+
 func ptr(s string) *string {
 	return &s
 }
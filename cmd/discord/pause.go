@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// pauseState records whether automatic checks are paused, and until when.
+// It is kept in memory only, so a restart resumes polling.
+type pauseState struct {
+	mu     sync.Mutex
+	paused bool
+	until  time.Time // zero means paused until resumed
+}
+
+// polling gates the automatic checks run by the ticker, webhook and reconnect.
+var polling = &pauseState{}
+
+// pause stops automatic checks, for d if it is positive, otherwise until resume.
+func (p *pauseState) pause(now time.Time, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+	p.until = time.Time{}
+	if d > 0 {
+		p.until = now.Add(d)
+	}
+}
+
+// resume restarts automatic checks and reports whether they were paused.
+func (p *pauseState) resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	wasPaused := p.paused
+	p.paused = false
+	p.until = time.Time{}
+	return wasPaused
+}
+
+// status reports whether checks are paused at now, resuming them once a
+// timed pause has expired.
+func (p *pauseState) status(now time.Time) (paused bool, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused && !p.until.IsZero() && !now.Before(p.until) {
+		p.paused = false
+		p.until = time.Time{}
+		slog.Info("Pause expired, automatic checks resumed")
+	}
+	return p.paused, p.until
+}
+
+// runAutomaticCheck runs a check unless automatic checks are paused.
+// /tailscale-refresh calls runScheduledCheck directly, so it works while paused.
+func runAutomaticCheck(s *discordgo.Session, cfg Config, httpClient *http.Client) {
+	if paused, until := polling.status(time.Now()); paused {
+		slog.Info("Automatic checks are paused, skipping", "until", until)
+		return
+	}
+	runScheduledCheck(s, cfg, httpClient, false)
+}
+
+func handlePauseCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config) {
+	if !isApprover(cfg, i.Member) {
+		respondNotApprover(s, i)
+		return
+	}
+
+	var duration time.Duration
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "duration" {
+			parsed, err := time.ParseDuration(opt.StringValue())
+			if err != nil || parsed <= 0 {
+				respondEphemeral(s, i, fmt.Sprintf("Invalid duration `%s`. Use a positive Go duration such as `30m` or `2h`.", opt.StringValue()))
+				return
+			}
+			duration = parsed
+		}
+	}
+
+	now := time.Now()
+	polling.pause(now, duration)
+	slog.Info("Automatic checks paused", "user", i.Member.User.Username, "duration", duration)
+
+	content := fmt.Sprintf("⏸️ Automatic checks paused by %s until `/tailscale-resume`.", i.Member.User.Username)
+	if duration > 0 {
+		content = fmt.Sprintf("⏸️ Automatic checks paused by %s until <t:%d:t> (<t:%d:R>).", i.Member.User.Username, now.Add(duration).Unix(), now.Add(duration).Unix())
+	}
+	respondPauseState(s, i, content)
+}
+
+func handleResumeCommand(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config) {
+	if !isApprover(cfg, i.Member) {
+		respondNotApprover(s, i)
+		return
+	}
+
+	// Expire a finished timed pause first so it is not reported as resumed here.
+	polling.status(time.Now())
+	if !polling.resume() {
+		respondEphemeral(s, i, "Automatic checks are not paused.")
+		return
+	}
+	slog.Info("Automatic checks resumed", "user", i.Member.User.Username)
+	respondPauseState(s, i, fmt.Sprintf("▶️ Automatic checks resumed by %s.", i.Member.User.Username))
+}
+
+// respondPauseState announces a pause or resume in the channel, so every
+// reviewer can see why prompts stopped or started.
+func respondPauseState(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// commandPermissionNames are the COMMAND_PERMISSIONS names accepted besides a
+// raw permission bitfield.
+var commandPermissionNames = map[string]int64{
+	"administrator":    discordgo.PermissionAdministrator,
+	"manage_guild":     discordgo.PermissionManageGuild,
+	"manage_channels":  discordgo.PermissionManageChannels,
+	"manage_roles":     discordgo.PermissionManageRoles,
+	"manage_messages":  discordgo.PermissionManageMessages,
+	"moderate_members": discordgo.PermissionModerateMembers,
+}
+
+// parseCommandPermissions parses COMMAND_PERMISSIONS, a comma-separated list
+// of permission names (e.g. "manage_guild") or a numeric bitfield. It returns
+// nil when value is empty, leaving the commands usable by everyone.
+func parseCommandPermissions(value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if bits, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if bits < 0 {
+			return nil, fmt.Errorf("must not be negative")
+		}
+		return &bits, nil
+	}
+
+	var bits int64
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		perm, ok := commandPermissionNames[name]
+		if !ok {
+			return nil, fmt.Errorf("has unknown permission %q", name)
+		}
+		bits |= perm
+	}
+	return &bits, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxTagProfiles is the number of options a Discord select menu can hold.
+const maxTagProfiles = 25
+
+// parseTagProfiles parses TAG_PROFILES, a JSON object mapping a profile name
+// to its tags, e.g. {"server": ["tag:server", "tag:prod"]}.
+func parseTagProfiles(value string) (map[string][]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var profiles map[string][]string
+	if err := json.Unmarshal([]byte(value), &profiles); err != nil {
+		return nil, fmt.Errorf("must be a JSON object of profile name to tags: %w", err)
+	}
+	if len(profiles) > maxTagProfiles {
+		return nil, fmt.Errorf("has %d profiles, at most %d are supported", len(profiles), maxTagProfiles)
+	}
+	for name, tags := range profiles {
+		if name == "" {
+			return nil, errors.New("has a profile with an empty name")
+		}
+		if len(tags) == 0 {
+			return nil, fmt.Errorf("profile %q has no tags", name)
+		}
+		for _, tag := range tags {
+			if !strings.HasPrefix(tag, "tag:") {
+				return nil, fmt.Errorf("profile %q has %q, tags must start with \"tag:\"", name, tag)
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// Discord's length limits for select menu text.
+const (
+	maxSelectOptionDescription = 100
+	maxSelectPlaceholder       = 150
+)
+
+// parseTagDescriptions parses TAG_DESCRIPTIONS, a JSON object mapping a tag
+// to a short description shown under it in the tag select menu, e.g.
+// {"tag:server": "Production servers"}. Descriptions over Discord's limit
+// are truncated with a warning.
+func parseTagDescriptions(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var descriptions map[string]string
+	if err := json.Unmarshal([]byte(value), &descriptions); err != nil {
+		return nil, fmt.Errorf("must be a JSON object of tag to description: %w", err)
+	}
+	for tag, description := range descriptions {
+		if !strings.HasPrefix(tag, "tag:") {
+			return nil, fmt.Errorf("has %q, tags must start with \"tag:\"", tag)
+		}
+		if len([]rune(description)) > maxSelectOptionDescription {
+			slog.Warn("TAG_DESCRIPTIONS entry is too long for Discord, truncating", "tag", tag, "maxLength", maxSelectOptionDescription)
+			descriptions[tag] = truncate(description, maxSelectOptionDescription)
+		}
+	}
+	return descriptions, nil
+}
+
+// showProfileSelectMenu replaces the approval message with a menu of tag
+// profiles, plus an "Advanced" button for picking individual tags.
+func showProfileSelectMenu(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, deviceID string) {
+	names := slices.Sorted(maps.Keys(cfg.TagProfiles))
+	options := make([]discordgo.SelectMenuOption, len(names))
+	for idx, name := range names {
+		options[idx] = discordgo.SelectMenuOption{
+			Label:       name,
+			Value:       name,
+			Description: truncate(strings.Join(cfg.TagProfiles[name], ", "), maxSelectOptionDescription),
+		}
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("**Select a tag profile to apply**\nDevice ID: `%s`", deviceID),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.SelectMenu{
+							CustomID:    encodeCustomID("select_profile", deviceID),
+							Placeholder: "Select a profile...",
+							Options:     options,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Advanced: pick tags",
+							Style:    discordgo.SecondaryButton,
+							CustomID: encodeCustomID("advanced", deviceID),
+						},
+						discordgo.Button{
+							Label:    "Cancel",
+							Style:    discordgo.SecondaryButton,
+							CustomID: encodeCustomID("cancel", deviceID),
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+// handleProfileSelect approves the device with the selected profile's tags.
+// Dual approval still applies.
+func handleProfileSelect(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client) {
+	action, deviceID, ok := decodeCustomID(i.MessageComponentData().CustomID)
+	values := i.MessageComponentData().Values
+	if !ok || action != "select_profile" || len(values) != 1 {
+		return
+	}
+
+	profile := values[0]
+	tags, ok := cfg.TagProfiles[profile]
+	if !ok {
+		respondEphemeral(s, i, fmt.Sprintf("Unknown tag profile %q.", profile))
+		return
+	}
+
+	slog.Info("Tag profile selected", "deviceID", deviceID, "profile", profile, "tags", tags, "user", i.Member.User.Username)
+
+	if requiresDualApproval(cfg, tags) {
+		requestSecondApproval(s, i, pendingConfirmation{DeviceID: deviceID, Tags: tags})
+		return
+	}
+
+	applyApproval(s, i, cfg, httpClient, deviceID, tags, false, i.Member.User.Username)
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
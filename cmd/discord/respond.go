@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// respondEphemeral replies to the interaction with a message only the user can
+// see. If the interaction was already acknowledged (for example by a double
+// click, or because the handler deferred it), the message is sent as an
+// ephemeral follow-up instead so the user still gets feedback.
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err == nil {
+		return
+	}
+	if !isAlreadyAcknowledged(err) {
+		slog.Error("Failed to send ephemeral response", "error", err)
+		return
+	}
+
+	slog.Warn("Interaction already acknowledged, sending ephemeral follow-up instead", "interactionID", i.ID)
+	if _, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	}); err != nil {
+		slog.Error("Failed to send ephemeral follow-up", "error", err)
+	}
+}
+
+func isAlreadyAcknowledged(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+	return restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeInteractionHasAlreadyBeenAcknowledged
+}
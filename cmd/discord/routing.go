@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// channelRoute posts approval prompts for devices whose name matches Pattern,
+// a path.Match glob, to ChannelID instead of the default channel.
+type channelRoute struct {
+	Pattern   string
+	ChannelID string
+}
+
+// parseChannelRouting parses CHANNEL_ROUTING, a comma-separated list of
+// pattern=channelID pairs such as "prod-*=123456789,ci-*=987654321". Routes
+// are tried in order and the first match wins.
+func parseChannelRouting(value string) ([]channelRoute, error) {
+	var routes []channelRoute
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, channelID, ok := strings.Cut(entry, "=")
+		pattern, channelID = strings.TrimSpace(pattern), strings.TrimSpace(channelID)
+		if !ok || pattern == "" || channelID == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected pattern=channelID", entry)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		routes = append(routes, channelRoute{Pattern: pattern, ChannelID: channelID})
+	}
+	return routes, nil
+}
+
+// routedChannelID returns the channel of the first route matching the
+// device's full or short name. ok is false when no route matches and the
+// prompt belongs in the default channel.
+func routedChannelID(routes []channelRoute, device PendingDevice) (channelID string, ok bool) {
+	for _, route := range routes {
+		full, _ := path.Match(route.Pattern, device.Name)
+		short, _ := path.Match(route.Pattern, device.ShortName)
+		if full || short {
+			return route.ChannelID, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestRoutedChannelID_FirstMatchingRouteWins(t *testing.T) {
+	routes, err := parseChannelRouting("prod-db-*=111, prod-*=222,ci-*.tailnet.ts.net=333")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		device PendingDevice
+		want   string
+	}{
+		{PendingDevice{Name: "prod-db-1.tailnet.ts.net", ShortName: "prod-db-1"}, "111"},
+		{PendingDevice{Name: "prod-web-1.tailnet.ts.net", ShortName: "prod-web-1"}, "222"},
+		{PendingDevice{Name: "ci-runner.tailnet.ts.net", ShortName: "ci-runner"}, "333"},
+		{PendingDevice{Name: "laptop.tailnet.ts.net", ShortName: "laptop"}, ""},
+	}
+	for _, tt := range tests {
+		got, ok := routedChannelID(routes, tt.device)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: expected %q, got %q (ok %t)", tt.device.Name, tt.want, got, ok)
+		}
+	}
+}
+
+func TestParseChannelRouting_RejectsInvalidEntries(t *testing.T) {
+	for _, value := range []string{"prod-*", "=123", "prod-*=", "[=123"} {
+		if _, err := parseChannelRouting(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// sessionState tracks whether the Discord gateway connection is usable, so
+// scheduled checks do not post while discordgo is reconnecting.
+type sessionState struct {
+	ready atomic.Bool
+	// missedCheck is set when a check was skipped while disconnected, so it
+	// can run once the session is back.
+	missedCheck atomic.Bool
+}
+
+var session = &sessionState{}
+
+func registerSessionHandlers(dg *discordgo.Session, cfg Config, httpClient *http.Client) {
+	dg.AddHandler(func(s *discordgo.Session, _ *discordgo.Disconnect) {
+		session.ready.Store(false)
+		slog.Warn("Discord session disconnected")
+	})
+
+	onReconnect := func(s *discordgo.Session, event string) {
+		session.ready.Store(true)
+		slog.Info("Discord session ready", "event", event)
+		if session.missedCheck.Swap(false) {
+			slog.Info("Running check skipped while disconnected")
+			go runAutomaticCheck(s, cfg, httpClient)
+		}
+	}
+	dg.AddHandler(func(s *discordgo.Session, _ *discordgo.Ready) {
+		onReconnect(s, "ready")
+	})
+	dg.AddHandler(func(s *discordgo.Session, _ *discordgo.Resumed) {
+		onReconnect(s, "resumed")
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type SnoozeResponse struct {
+	SnoozedUntil time.Time `json:"snoozed_until"`
+}
+
+// handleSnoozeButton asks the API to hide the device from pending checks for
+// cfg.SnoozeDuration and closes its approval message. The next check after
+// the snooze ends posts a fresh approval message.
+func handleSnoozeButton(s *discordgo.Session, i *discordgo.InteractionCreate, cfg Config, httpClient *http.Client, deviceID string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+
+	body, _ := json.Marshal(map[string]string{"duration": cfg.SnoozeDuration.String()})
+	tailnetCfg, id := cfg.forDevice(deviceID)
+	resp, err := apiPost(tailnetCfg, httpClient, "/snooze/"+id, body)
+	if err != nil {
+		slog.Error("Failed to call controller", "error", err)
+		s.ChannelMessageSend(i.ChannelID, apiErrorMessage("Failed to snooze device", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Controller returned error", "status", resp.StatusCode)
+		s.ChannelMessageSend(i.ChannelID, fmt.Sprintf("Failed to snooze device: %s", resp.Status))
+		return
+	}
+
+	var res SnoozeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		res.SnoozedUntil = time.Now().Add(cfg.SnoozeDuration)
+	}
+
+	slog.Info("Device snoozed", "deviceID", deviceID, "until", res.SnoozedUntil, "user", i.Member.User.Username)
+	approvals.untrack(i.Message.ID)
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:    ptr(fmt.Sprintf("%s\n\n💤 **Snoozed** by %s until <t:%d:f> (<t:%d:R>)", i.Message.Content, i.Member.User.Username, res.SnoozedUntil.Unix(), res.SnoozedUntil.Unix())),
+		Components: &[]discordgo.MessageComponent{},
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// tailnetTarget is one tailnet served by its own API deployment.
+type tailnetTarget struct {
+	Name    string
+	APIURLs []string // tried in order, like Config.APIURLs
+}
+
+// parseTailnets parses TAILNETS, a comma-separated list of name=url pairs
+// such as "prod=http://api-prod:8080,staging=http://api-staging:8080".
+// Repeating a name adds a failover URL for that tailnet. Names cannot contain
+// "/" because they prefix device IDs.
+func parseTailnets(value string) ([]tailnetTarget, error) {
+	var tailnets []tailnetTarget
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, apiURL, ok := strings.Cut(entry, "=")
+		name, apiURL = strings.TrimSpace(name), strings.TrimSuffix(strings.TrimSpace(apiURL), "/")
+		if !ok || name == "" || apiURL == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected name=url", entry)
+		}
+		if strings.ContainsAny(name, "/:") {
+			return nil, fmt.Errorf("tailnet name %q must not contain '/' or ':'", name)
+		}
+
+		idx := slices.IndexFunc(tailnets, func(t tailnetTarget) bool { return t.Name == name })
+		if idx < 0 {
+			tailnets = append(tailnets, tailnetTarget{Name: name})
+			idx = len(tailnets) - 1
+		}
+		tailnets[idx].APIURLs = append(tailnets[idx].APIURLs, apiURL)
+	}
+	return tailnets, nil
+}
+
+// tailnetTargets returns the configured tailnets, or a single unnamed one
+// using APIURLs when TAILNETS is not set.
+func (c Config) tailnetTargets() []tailnetTarget {
+	if len(c.Tailnets) == 0 {
+		return []tailnetTarget{{APIURLs: c.APIURLs}}
+	}
+	return c.Tailnets
+}
+
+// forTailnet returns the config with APIURLs pointing at the named tailnet's
+// API. An empty or unknown name leaves the config unchanged.
+func (c Config) forTailnet(name string) Config {
+	for _, t := range c.Tailnets {
+		if t.Name == name {
+			c.APIURLs = t.APIURLs
+			break
+		}
+	}
+	return c
+}
+
+// qualifyDeviceID prefixes a device ID with its tailnet ("prod/12345"), so
+// button custom IDs and tracked messages route back to the right API. IDs
+// from a single-tailnet setup are left as they are.
+func qualifyDeviceID(tailnet, deviceID string) string {
+	if tailnet == "" {
+		return deviceID
+	}
+	return tailnet + "/" + deviceID
+}
+
+// splitDeviceID splits a device ID produced by qualifyDeviceID into its
+// tailnet name and the bare Tailscale device ID.
+func (c Config) splitDeviceID(deviceID string) (tailnet, id string) {
+	if len(c.Tailnets) == 0 {
+		return "", deviceID
+	}
+	tailnet, id, ok := strings.Cut(deviceID, "/")
+	if !ok {
+		return "", deviceID
+	}
+	return tailnet, id
+}
+
+// forDevice returns the config for a device's tailnet along with the bare
+// Tailscale device ID.
+func (c Config) forDevice(deviceID string) (Config, string) {
+	tailnet, id := c.splitDeviceID(deviceID)
+	return c.forTailnet(tailnet), id
+}
+
+// tailnetOptions returns the optional "tailnet" command option listing each
+// of TAILNETS, or nothing without TAILNETS.
+func tailnetOptions(cfg Config) []*discordgo.ApplicationCommandOption {
+	if len(cfg.Tailnets) == 0 {
+		return nil
+	}
+	option := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "tailnet",
+		Description: "Only search this tailnet",
+	}
+	for _, t := range cfg.Tailnets {
+		option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{Name: t.Name, Value: t.Name})
+	}
+	return []*discordgo.ApplicationCommandOption{option}
+}
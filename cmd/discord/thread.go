@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// dailyThreadArchiveMinutes keeps a day's thread open for a day after its last message.
+const dailyThreadArchiveMinutes = 1440
+
+// dailyThread tracks the thread approval prompts are posted to when
+// DAILY_THREAD is enabled, starting a new one when the date changes.
+type dailyThread struct {
+	mu       sync.Mutex
+	date     string
+	threadID string
+}
+
+var approvalThread = &dailyThread{}
+
+// channelFor returns today's thread, creating it under parentID if needed.
+// If the thread cannot be created, parentID is returned so the prompt is
+// still posted; creation is retried on the next call.
+func (d *dailyThread) channelFor(s *discordgo.Session, parentID string, now time.Time) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	date := now.Format("2006-01-02")
+	if d.date == date && d.threadID != "" {
+		return d.threadID
+	}
+
+	thread, err := s.ThreadStart(parentID, "Device approvals — "+date, discordgo.ChannelTypeGuildPublicThread, dailyThreadArchiveMinutes)
+	if err != nil {
+		slog.Error("Failed to start daily thread, posting to channel instead", "date", date, "error", err)
+		return parentID
+	}
+
+	slog.Info("Started daily approval thread", "date", date, "threadID", thread.ID)
+	d.date = date
+	d.threadID = thread.ID
+	return thread.ID
+}
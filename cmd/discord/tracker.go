@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxRepostsPerDevice limits how many times a deleted approval message is
+// re-posted for the same device, so a bot or user deleting it repeatedly
+// cannot cause a loop.
+const maxRepostsPerDevice = 3
+
+// approvalTracker remembers which messages are open approval prompts so a
+// deleted prompt can be re-posted while its device is still pending.
+type approvalTracker struct {
+	mu       sync.Mutex
+	messages map[string]*trackedApproval // message ID -> prompt
+	reposts  map[string]int              // device ID -> re-post count
+}
+
+// trackedApproval is an open approval prompt.
+type trackedApproval struct {
+	device    PendingDevice
+	channelID string
+	postedAt  time.Time
+	// escalation is the highest escalation level sent for the prompt.
+	escalation escalationLevel
+}
+
+var approvals = &approvalTracker{
+	messages: make(map[string]*trackedApproval),
+	reposts:  make(map[string]int),
+}
+
+func (t *approvalTracker) track(messageID, channelID string, device PendingDevice, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages[messageID] = &trackedApproval{device: device, channelID: channelID, postedAt: now}
+}
+
+// lookup returns the device an approval message was posted for.
+func (t *approvalTracker) lookup(messageID string) (PendingDevice, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	approval, ok := t.messages[messageID]
+	if !ok {
+		return PendingDevice{}, false
+	}
+	return approval.device, true
+}
+
+// untrack forgets an approval message and returns the device it was for.
+func (t *approvalTracker) untrack(messageID string) (PendingDevice, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	approval, ok := t.messages[messageID]
+	if !ok {
+		return PendingDevice{}, false
+	}
+	delete(t.messages, messageID)
+	return approval.device, true
+}
+
+// allowRepost records a re-post for deviceID and reports whether the limit
+// has not been reached yet.
+func (t *approvalTracker) allowRepost(deviceID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reposts[deviceID] >= maxRepostsPerDevice {
+		return false
+	}
+	t.reposts[deviceID]++
+	return true
+}
+
+func handleMessageDelete(s *discordgo.Session, m *discordgo.MessageDelete, cfg Config, httpClient *http.Client) {
+	device, ok := approvals.untrack(m.ID)
+	if !ok {
+		return
+	}
+
+	pending, _, err := fetchPendingDevices(cfg, httpClient)
+	if err != nil {
+		slog.Error("Failed to check deleted approval message", "deviceID", device.ID, "error", err)
+		return
+	}
+
+	stillPending := false
+	for _, p := range pending {
+		if p.ID == device.ID {
+			stillPending = true
+			break
+		}
+	}
+	if !stillPending {
+		return
+	}
+
+	if !approvals.allowRepost(device.ID) {
+		slog.Warn("Approval message deleted again, not re-posting", "deviceID", device.ID, "maxReposts", maxRepostsPerDevice)
+		return
+	}
+
+	slog.Info("Approval message deleted, re-posting", "deviceID", device.ID, "messageID", m.ID)
+	sendDeviceApprovalMessage(s, cfg, m.ChannelID, device)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// webhookSignatureHeader is the header Tailscale uses to sign webhook deliveries.
+// Format: "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">"
+const webhookSignatureHeader = "Tailscale-Webhook-Signature"
+
+// webhookMaxAge bounds how old a signed delivery may be, to limit replays.
+const webhookMaxAge = 5 * time.Minute
+
+// webhookMaxBodyBytes caps how much of an unauthenticated delivery is read
+// before its signature is checked.
+const webhookMaxBodyBytes = 1 << 20
+
+type WebhookEvent struct {
+	Type    string `json:"type"`
+	Tailnet string `json:"tailnet"`
+	Message string `json:"message"`
+}
+
+// relevantWebhookEvents are the Tailscale event types that can produce a pending device.
+var relevantWebhookEvents = map[string]bool{
+	"nodeCreated":       true,
+	"nodeNeedsApproval": true,
+}
+
+func newWebhookHandler(s *discordgo.Session, cfg Config, httpClient *http.Client) http.Handler {
+	mux := http.NewServeMux()
+
+	// POST /webhook/tailscale - Receives Tailscale webhook events and runs a
+	// pending device check when a node is created or needs approval.
+	// Returns 401 if the signature does not match WEBHOOK_SECRET, or 413 if the
+	// body exceeds 1 MiB.
+	mux.HandleFunc("POST /webhook/tailscale", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBodyBytes))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if err := verifyWebhookSignature(r.Header.Get(webhookSignatureHeader), body, cfg.WebhookSecret, time.Now()); err != nil {
+			slog.Warn("Rejected webhook", "error", err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var events []WebhookEvent
+		if err := json.Unmarshal(body, &events); err != nil {
+			slog.Error("Failed to decode webhook body", "error", err)
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		relevant := false
+		for _, event := range events {
+			slog.Info("Webhook event received", "type", event.Type, "tailnet", event.Tailnet)
+			if relevantWebhookEvents[event.Type] {
+				relevant = true
+			}
+		}
+
+		// Respond before notifying so Tailscale does not time out the delivery.
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+
+		if relevant {
+			go runAutomaticCheck(s, cfg, httpClient)
+		}
+	})
+
+	return mux
+}
+
+func verifyWebhookSignature(header string, body []byte, secret string, now time.Time) error {
+	if header == "" {
+		return errors.New("missing signature header")
+	}
+
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == "" || signature == "" {
+		return errors.New("malformed signature header")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > webhookMaxAge || age < -webhookMaxAge {
+		return errors.New("signature timestamp out of range")
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signWebhook(timestamp int64, body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", timestamp, body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const body = `[{"type":"nodeNeedsApproval"}]`
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{"valid", signWebhook(now.Unix(), body, "secret"), false},
+		{"valid within clock skew", signWebhook(now.Add(time.Minute).Unix(), body, "secret"), false},
+		{"missing header", "", true},
+		{"malformed header", "v1=abcd", true},
+		{"signed with another secret", signWebhook(now.Unix(), body, "other"), true},
+		{"signed for another body", signWebhook(now.Unix(), "[]", "secret"), true},
+		{"malformed signature", fmt.Sprintf("t=%d,v1=zz", now.Unix()), true},
+		{"stale timestamp", signWebhook(now.Add(-webhookMaxAge-time.Minute).Unix(), body, "secret"), true},
+		{"future timestamp", signWebhook(now.Add(webhookMaxAge+time.Minute).Unix(), body, "secret"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyWebhookSignature(tt.header, []byte(body), "secret", now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_BodyTooLarge(t *testing.T) {
+	body := strings.Repeat("x", webhookMaxBodyBytes+1)
+	req := httptest.NewRequest(http.MethodPost, "/webhook/tailscale", strings.NewReader(body))
+	req.Header.Set(webhookSignatureHeader, signWebhook(time.Now().Unix(), body, "secret"))
+	rec := httptest.NewRecorder()
+	newWebhookHandler(nil, Config{WebhookSecret: "secret"}, nil).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rec.Code)
+	}
+}